@@ -0,0 +1,95 @@
+// File: assert.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TestingT is the part of testing.TB that the assertion functions use, so
+// that this package does not have to import the testing package into every
+// program that uses it. A *testing.T, *testing.B or *testing.F can be
+// passed wherever a TestingT is wanted.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertOutputContains captures the merged standard output and standard
+// error of function `f`, as CaptureOutput does, and fails the test `t` if
+// the output does not contain `substr`. The test is also failed if the
+// output cannot be captured, e.g., because `f` panics.
+//
+// Failures are reported at the line of the test that called this function.
+func AssertOutputContains(t TestingT, substr string, f func()) {
+	t.Helper()
+	output, err := CaptureOutput(f)
+	if err != nil {
+		t.Errorf("cannot capture output: %v", err)
+		return
+	}
+	if !strings.Contains(output, substr) {
+		t.Errorf("output does not contain %q\noutput: %q", substr, output)
+	}
+} // AssertOutputContains
+
+// AssertOutputEquals captures the merged standard output and standard
+// error of function `f`, as CaptureOutput does, and fails the test `t` if
+// the output is not exactly `want`, reporting the first line that differs.
+// The test is also failed if the output cannot be captured.
+//
+// Failures are reported at the line of the test that called this function.
+func AssertOutputEquals(t TestingT, want string, f func()) {
+	t.Helper()
+	output, err := CaptureOutput(f)
+	if err != nil {
+		t.Errorf("cannot capture output: %v", err)
+		return
+	}
+	if output != want {
+		t.Errorf("output differs from what is wanted\n%s\nwant: %q\n got: %q",
+			firstDifference(want, output), want, output)
+	}
+} // AssertOutputEquals
+
+// firstDifference describes the first line that differs between `want`
+// and `got`, which must not be equal.
+func firstDifference(want, got string) string {
+	wantLines := strings.SplitAfter(want, "\n")
+	gotLines := strings.SplitAfter(got, "\n")
+	for i := 0; ; i++ {
+		var wantLine, gotLine string
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+		if wantLine != gotLine {
+			return fmt.Sprintf("line %d: want %q, got %q", i+1, wantLine, gotLine)
+		}
+	}
+} // firstDifference
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,72 @@
+// File: assert_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeT is a TestingT that records the failures reported to it.
+type fakeT struct {
+	failures []string
+}
+
+func (ft *fakeT) Helper() {}
+
+func (ft *fakeT) Errorf(format string, args ...any) {
+	ft.failures = append(ft.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertOutputContains(t *testing.T) {
+	AssertOutputContains(t, "Hello", func() { fmt.Println("Hello, stranger!") })
+	var ft fakeT
+	AssertOutputContains(&ft, "Goodbye", func() { fmt.Println("Hello, stranger!") })
+	if len(ft.failures) != 1 || !strings.Contains(ft.failures[0], `"Goodbye"`) {
+		t.Errorf("failures = %q, want one about \"Goodbye\"", ft.failures)
+	}
+	ft = fakeT{}
+	AssertOutputContains(&ft, "Hello", func() { panic("oops") })
+	if len(ft.failures) != 1 || !strings.Contains(ft.failures[0], "cannot capture") {
+		t.Errorf("failures = %q, want one about the panic", ft.failures)
+	}
+} // TestAssertOutputContains
+
+func TestAssertOutputEquals(t *testing.T) {
+	AssertOutputEquals(t, "a\nb\n", func() { fmt.Print("a\nb\n") })
+	var ft fakeT
+	AssertOutputEquals(&ft, "a\nb\nc\n", func() { fmt.Print("a\nx\nc\n") })
+	if len(ft.failures) != 1 ||
+		!strings.Contains(ft.failures[0], `line 2: want "b\n", got "x\n"`) {
+		t.Errorf("failures = %q, want one reporting line 2", ft.failures)
+	}
+	ft = fakeT{}
+	AssertOutputEquals(&ft, "a\n", func() { fmt.Print("a\nb\n") })
+	if len(ft.failures) != 1 ||
+		!strings.Contains(ft.failures[0], `line 2: want "", got "b\n"`) {
+		t.Errorf("failures = %q, want one reporting line 2", ft.failures)
+	}
+} // TestAssertOutputEquals
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,157 @@
+// File: bytesize.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FormatBytes returns the byte count `n` in a form that is easy for people
+// to read, using binary units, i.e., powers of 1024, and up to one decimal
+// place, e.g., "512 B", "1.5 MiB", or "2 GiB". A negative `n` keeps its sign,
+// e.g., "-1.5 KiB".
+//
+// This is handy for logging the sizes of files:
+//
+//	```go
+//	log.Info().Str("size", veil.FormatBytes(info.Size())).Msg("rotated log file")
+func FormatBytes(n int64) string {
+	return formatBytes(n, 1024, binaryUnits)
+} // FormatBytes
+
+// FormatBytesSI is like FormatBytes, but uses decimal (SI) units, i.e.,
+// powers of 1000, e.g., "1.5 MB" rather than "1.4 MiB".
+func FormatBytesSI(n int64) string {
+	return formatBytes(n, 1000, siUnits)
+} // FormatBytesSI
+
+// ParseBytes parses a byte count, such as "10MB", "512KiB", "1.5 gib", or
+// "4096", e.g., from a command line flag for the size of the log file at
+// which it is rotated:
+//
+//	```go
+//	maxBytes, err := veil.ParseBytes(*maxSizeFlag)
+//	if err != nil {
+//	    return err
+//	}
+//	closer, err := veil.SetGlobalZerologRotating("app.log", level, maxBytes, 5)
+//
+// Units are case-insensitive, and may be separated from the number by
+// spaces. Units without an "i", e.g., "kB" or "MB", are decimal (SI) units,
+// i.e., powers of 1000, while those with one, e.g., "KiB" or "MiB", are
+// binary units, i.e., powers of 1024, as used by FormatBytesSI and
+// FormatBytes, respectively. A bare number, or one in "B", is a number of
+// bytes. The number may have a fractional part, but the result is rounded
+// down to a whole number of bytes.
+//
+// An error is returned for anything else, including negative numbers, and
+// byte counts too large for an int64.
+func ParseBytes(s string) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+	numLen := strings.IndexFunc(trimmed, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if numLen < 0 {
+		numLen = len(trimmed)
+	}
+	num := trimmed[:numLen]
+	unit := strings.ToLower(strings.TrimSpace(trimmed[numLen:]))
+	mult, ok := byteUnits[unit]
+	switch {
+	case strings.HasPrefix(trimmed, "-"):
+		return 0, fmt.Errorf("invalid byte size %q: negative", s)
+	case num == "":
+		return 0, fmt.Errorf("invalid byte size %q: no number", s)
+	case !ok:
+		return 0, fmt.Errorf("invalid byte size %q: unknown unit %q", s, unit)
+	}
+	if n, err := strconv.ParseInt(num, 10, 64); err == nil {
+		if n > math.MaxInt64/mult {
+			return 0, fmt.Errorf("invalid byte size %q: too large", s)
+		}
+		return n * mult, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q: %w", s, err)
+	}
+	f *= float64(mult)
+	// float64(math.MaxInt64) rounds up to 1 << 63, which is too large
+	if f >= float64(math.MaxInt64) {
+		return 0, fmt.Errorf("invalid byte size %q: too large", s)
+	}
+	return int64(f), nil
+} // ParseBytes
+
+// byteUnits maps the lowercase units accepted by ParseBytes
+// to the number of bytes in each of them.
+var byteUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"pb":  1e15,
+	"eb":  1e18,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+	"eib": 1 << 60,
+}
+
+// binaryUnits and siUnits are the units, from kilobytes up, that byte
+// counts are formatted with by FormatBytes and FormatBytesSI, respectively.
+var (
+	binaryUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	siUnits     = []string{"kB", "MB", "GB", "TB", "PB", "EB"}
+)
+
+// formatBytes formats the byte count `n` using `units`,
+// each of which is `base` times larger than the one before.
+func formatBytes(n int64, base float64, units []string) string {
+	sign := ""
+	size := uint64(n)
+	if n < 0 {
+		// negating the unsigned value also works for math.MinInt64
+		sign, size = "-", -size
+	}
+	if float64(size) < base {
+		return fmt.Sprintf("%s%d B", sign, size)
+	}
+	val, unit := float64(size)/base, 0
+	// e.g., 1023.96 KiB rounds up to 1 MiB, rather than to 1024 KiB
+	for math.Round(val*10)/10 >= base && unit < len(units)-1 {
+		val /= base
+		unit++
+	}
+	formatted := strconv.FormatFloat(math.Round(val*10)/10, 'f', 1, 64)
+	return sign + strings.TrimSuffix(formatted, ".0") + " " + units[unit]
+} // formatBytes
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
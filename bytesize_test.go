@@ -0,0 +1,105 @@
+// File: bytesize_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n       int64
+		binary  string
+		decimal string
+	}{
+		{n: 0, binary: "0 B", decimal: "0 B"},
+		{n: 999, binary: "999 B", decimal: "999 B"},
+		{n: 1000, binary: "1000 B", decimal: "1 kB"},
+		{n: 1023, binary: "1023 B", decimal: "1 kB"},
+		{n: 1024, binary: "1 KiB", decimal: "1 kB"},
+		{n: 1536, binary: "1.5 KiB", decimal: "1.5 kB"},
+		{n: 1<<20 - 1, binary: "1 MiB", decimal: "1 MB"},
+		{n: 3 << 19, binary: "1.5 MiB", decimal: "1.6 MB"},
+		{n: 2 << 30, binary: "2 GiB", decimal: "2.1 GB"},
+		{n: 1e12, binary: "931.3 GiB", decimal: "1 TB"},
+		{n: -1536, binary: "-1.5 KiB", decimal: "-1.5 kB"},
+		{n: -1, binary: "-1 B", decimal: "-1 B"},
+		{n: math.MaxInt64, binary: "8 EiB", decimal: "9.2 EB"},
+		{n: math.MinInt64, binary: "-8 EiB", decimal: "-9.2 EB"},
+	}
+	for _, tt := range tests {
+		if got := FormatBytes(tt.n); got != tt.binary {
+			t.Errorf("FormatBytes(%d) = %q, want %q", tt.n, got, tt.binary)
+		}
+		if got := FormatBytesSI(tt.n); got != tt.decimal {
+			t.Errorf("FormatBytesSI(%d) = %q, want %q", tt.n, got, tt.decimal)
+		}
+	}
+} // TestFormatBytes
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int64
+	}{
+		{s: "4096", want: 4096},
+		{s: "0", want: 0},
+		{s: "12B", want: 12},
+		{s: "10MB", want: 10_000_000},
+		{s: "10mb", want: 10_000_000},
+		{s: "10MiB", want: 10 << 20},
+		{s: "512KiB", want: 512 << 10},
+		{s: "512 kb", want: 512_000},
+		{s: "1gb", want: 1e9},
+		{s: " 1.5 GiB ", want: 3 << 29},
+		{s: "0.5kB", want: 500},
+		{s: "1.0001KiB", want: 1024},
+	}
+	for _, tt := range tests {
+		if got, err := ParseBytes(tt.s); err != nil || got != tt.want {
+			t.Errorf("ParseBytes(%q) = %d, %v, want %d", tt.s, got, err, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		s, reason string
+	}{
+		{s: "", reason: "no number"},
+		{s: "MB", reason: "no number"},
+		{s: "-5MB", reason: "negative"},
+		{s: "10 parsecs", reason: "unknown unit"},
+		{s: "10MBs", reason: "unknown unit"},
+		{s: "1.2.3MB", reason: "invalid syntax"},
+		{s: "8EiB", reason: "too large"},
+		{s: "9.3EB", reason: "too large"},
+	} {
+		got, err := ParseBytes(tt.s)
+		if err == nil || !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("ParseBytes(%q) = %d, %v, want an error saying %q",
+				tt.s, got, err, tt.reason)
+		}
+	}
+} // TestParseBytes
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
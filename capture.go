@@ -0,0 +1,899 @@
+// File: capture.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	stdlog "log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// captureMu serializes every function that swaps the process-wide
+// standard streams, so that concurrent captures (for instance from
+// tests that use `t.Parallel()`) cannot clobber each other.
+var captureMu sync.Mutex
+
+// CaptureStreams captures and returns the output of function `f`,
+// keeping standard output and standard error apart.
+//
+// Unlike CaptureOutput, which merges both streams, each stream is
+// redirected to its own pipe, so `stdout` only contains what `f`
+// wrote to `os.Stdout` and `stderr` only contains what `f` wrote
+// to `os.Stderr`, however the writes were interleaved.
+//
+// When this function returns, `os.Stdout` and `os.Stderr` are restored
+// to the streams that they originally referred to. A panic in `f` is
+// recovered and returned as an error, as it is by CaptureOutput.
+//
+// Captures are serialized: concurrent calls to CaptureStreams,
+// CaptureOutput, or any other capture function, run one at a time.
+func CaptureStreams(f func()) (stdout string, stderr string, err error) {
+	return captureStreams(nil, f)
+} // CaptureStreams
+
+// RunWithIO runs function `f` with its standard input reading `stdin`,
+// and captures and returns its standard output and standard error
+// separately, as CaptureStreams does.
+//
+// `f` reads end of file once it has read all of `stdin`, so this can be
+// used to test interactive command line programs. All three of `os.Stdin`,
+// `os.Stdout`, and `os.Stderr` are restored when this function returns.
+func RunWithIO(stdin string, f func()) (stdout string, stderr string, err error) {
+	return captureStreams(strings.NewReader(stdin), f)
+} // RunWithIO
+
+// captureStreams captures the standard output and standard error of
+// function `f` separately. If `stdin` is not nil then `f` has its standard
+// input redirected to read from `stdin`.
+func captureStreams(stdin io.Reader, f func()) (stdout string, stderr string, err error) {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	defer restoreStreams()()
+	var outReader, outWriter, errReader, errWriter *os.File
+	if outReader, outWriter, err = os.Pipe(); err != nil {
+		return "", "", err
+	}
+	if errReader, errWriter, err = os.Pipe(); err != nil {
+		outReader.Close()
+		outWriter.Close()
+		return "", "", err
+	}
+	if stdin != nil {
+		var inReader, inWriter *os.File
+		if inReader, inWriter, err = os.Pipe(); err != nil {
+			outReader.Close()
+			outWriter.Close()
+			errReader.Close()
+			errWriter.Close()
+			return "", "", err
+		}
+		// unblocks the feeding goroutine if `f` did not read all of `stdin`
+		defer inReader.Close()
+		os.Stdin = inReader
+		go func() {
+			// the input is fed in the background since it may not
+			// fit in the pipe, and `f` has not started reading yet
+			io.Copy(inWriter, stdin) // nolint:errcheck
+			// closing the writer lets `f` see end of file
+			inWriter.Close()
+		}()
+	}
+	os.Stdout = outWriter
+	os.Stderr = errWriter
+	outC := drain(outReader)
+	errC := drain(errReader)
+	err = runRecovered(f, outWriter, errWriter)
+	// both readers must reach EOF before returning
+	// otherwise the tail of either stream may be lost
+	return <-outC, <-errC, err
+} // captureStreams
+
+// CaptureInterleaved captures and returns the merged standard output and
+// standard error of function `f`, exactly as CaptureOutput does, but with
+// a name that states the guarantee that some tests depend on: the output
+// of the two streams is interleaved in the order in which it was written.
+//
+// The guarantee holds because both `os.Stdout` and `os.Stderr` are
+// redirected to the same pipe, rather than to a pipe each as they are by
+// CaptureStreams, so there is only one sequence of writes. Writes made one
+// after the other, e.g., by the same goroutine, are captured in that order,
+// however rapidly `f` alternates between the streams. Writes made at the
+// same time by different goroutines are captured in the order the pipe
+// receives them, which is unspecified.
+func CaptureInterleaved(f func()) (combined string, err error) {
+	return CaptureOutput(f)
+} // CaptureInterleaved
+
+// CaptureOutputWithFlush captures and returns the merged standard output
+// and standard error of function `f`, as CaptureOutput does, calling
+// `flush` after `f` returns, even if `f` panics, but before the capture
+// ends.
+//
+// This is needed when `f` writes through its own buffered writer, such as
+// a bufio.Writer wrapped around `os.Stdout`: whatever is still in its buffer
+// when `f` returns has not yet reached the capture pipe, and would be lost
+// otherwise, e.g.:
+//
+//	```go
+//	var w *bufio.Writer
+//	output, err := veil.CaptureOutputWithFlush(func() {
+//	    w = bufio.NewWriter(os.Stdout)
+//	    fmt.Fprint(w, "buffered")
+//	}, func() {
+//	    w.Flush()
+//	})
+//
+// Note that the writer must be created while `f` runs, so that it wraps
+// the capture pipe rather than the original standard output.
+func CaptureOutputWithFlush(f func(), flush func()) (string, error) {
+	return captureToPooled(func(buff *bytes.Buffer) error {
+		return captureTo(buff, func() {
+			defer flush()
+			f()
+		})
+	})
+} // CaptureOutputWithFlush
+
+// CaptureOutputOf captures and returns the merged standard output and
+// standard error of function `f`, as CaptureOutput does, along with the
+// value that `f` returns:
+//
+//	```go
+//	output, n, err := veil.CaptureOutputOf(func() int {
+//	    return printReport()
+//	})
+//
+// As with CaptureOutput, the standard streams have been restored, and all
+// of the output has been read, when this function returns. If `f` panics
+// then the zero value is returned as its result, along with the error.
+func CaptureOutputOf[T any](f func() T) (output string, result T, err error) {
+	output, err = CaptureOutput(func() {
+		result = f()
+	})
+	return output, result, err
+} // CaptureOutputOf
+
+// CaptureOutputBytes captures and returns the merged standard output and
+// standard error of function `f`, as CaptureOutput does, but as raw bytes.
+//
+// The bytes are exactly those that `f` wrote, which matters when `f` writes
+// binary data, or text that is not valid UTF-8, e.g., an image to `stdout`.
+func CaptureOutputBytes(f func()) ([]byte, error) {
+	var buff bytes.Buffer
+	err := captureTo(&buff, f)
+	return buff.Bytes(), err
+} // CaptureOutputBytes
+
+// CaptureOutputSized captures and returns the merged standard output and
+// standard error of function `f`, as CaptureOutput does, but first grows
+// the capture buffer to hold `hint` bytes. When the approximate size of the
+// output is known, this saves repeatedly growing the buffer as large output
+// is captured.
+//
+// The hint only affects performance: output larger than `hint` is still
+// captured in full, and a `hint` of zero or less is ignored.
+func CaptureOutputSized(f func(), hint int) (string, error) {
+	var buff bytes.Buffer
+	if hint > 0 {
+		// the buffer reads from the pipe in chunks, and always wants room
+		// for another bytes.MinRead bytes, even once `hint` bytes are read
+		buff.Grow(hint + bytes.MinRead)
+	}
+	err := captureTo(&buff, f)
+	return buff.String(), err
+} // CaptureOutputSized
+
+// CaptureOutputLimited captures and returns the merged standard output and
+// standard error of function `f`, as CaptureOutput does, but keeps at most
+// the first `maxBytes` bytes of it. This protects the calling program from
+// running out of memory when `f` writes far more than expected.
+//
+// Everything past `maxBytes` is still read, and thrown away, until `f`
+// returns, so that `f` never blocks on a full pipe. The returned
+// `truncated` flag reports whether any output was thrown away.
+func CaptureOutputLimited(f func(), maxBytes int) (output string, truncated bool, err error) {
+	buff := &limitedBuffer{max: maxBytes}
+	err = captureTo(buff, f)
+	return buff.buff.String(), buff.truncated, err
+} // CaptureOutputLimited
+
+// limitedBuffer is an io.Writer that keeps at most `max` of the bytes
+// written to it, silently discarding the rest.
+//
+// The buffer is deliberately not embedded, as its ReadFrom method would
+// let io.Copy bypass the limit.
+type limitedBuffer struct {
+	buff      bytes.Buffer
+	max       int
+	truncated bool
+}
+
+// Write keeps as much of `p` as fits within the limit. It always reports
+// that all of `p` was written, so that copying to it carries on draining.
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	room := b.max - b.buff.Len()
+	if room < 0 {
+		room = 0
+	}
+	if len(p) > room {
+		b.truncated = true
+		b.buff.Write(p[:room])
+		return len(p), nil
+	}
+	return b.buff.Write(p)
+} // Write
+
+// CaptureOutputLines captures and returns the merged standard output and
+// standard error of function `f`, as CaptureOutput does, and also calls
+// `onLine` with each line of that output as soon as the line is complete.
+// This lets long running captures be reacted to while they are running.
+//
+// Lines are passed to `onLine` without their line endings ("\n" or "\r\n"),
+// as by bufio.ScanLines, but without any limit on their length. They are
+// passed one at a time, in order, from a goroutine other than the caller's.
+//
+// A final line that does not end with a newline is not complete, so it is
+// not passed to `onLine`; it is, however, still part of the returned output.
+func CaptureOutputLines(f func(), onLine func(line string)) (string, error) {
+	lines := &lineWriter{onLine: onLine}
+	err := captureTo(lines, f)
+	return lines.all.String(), err
+} // CaptureOutputLines
+
+// CaptureOutputSlice captures the merged standard output and standard error
+// of function `f`, as CaptureOutput does, and returns that output split into
+// lines, without their line endings ("\n" or "\r\n"), as CaptureOutputLines
+// passes them to its callback:
+//
+//	```go
+//	lines, err := veil.CaptureOutputSlice(func() { fmt.Print("one\ntwo\n") })
+//	// lines is []string{"one", "two"}
+//
+// A final newline does not start another, empty, line; a final line that
+// does not end with a newline is still returned, though. No output at all
+// is no lines, i.e., a nil slice, rather than a slice of one empty string.
+//
+// If `f` panics then the lines that it output before it panicked are
+// returned, along with the error.
+func CaptureOutputSlice(f func()) ([]string, error) {
+	output, err := CaptureOutput(f)
+	if output == "" {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines, err
+} // CaptureOutputSlice
+
+// lineWriter is an io.Writer that keeps everything written to it,
+// and calls `onLine` with each complete line as soon as it is written.
+type lineWriter struct {
+	onLine  func(line string)
+	all     bytes.Buffer
+	partial []byte
+}
+
+// Write keeps `p`, and calls `onLine` for each line that `p` completes.
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.all.Write(p)
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.onLine(string(bytes.TrimSuffix(w.partial[:i], []byte("\r"))))
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+} // Write
+
+// CaptureOutputReader starts capturing the merged standard output and
+// standard error of function `f`, as CaptureOutput does, but instead of
+// buffering the output it returns a reader that streams it, along with a
+// channel that delivers the error of the capture once it has finished. This
+// lets very large captures be processed without holding them in memory:
+//
+//	```go
+//	output, errc := veil.CaptureOutputReader(generateReport)
+//	_, copyErr := io.Copy(io.Discard, output)
+//	output.Close()
+//	if err := errors.Join(copyErr, <-errc); err != nil {
+//	    return err
+//	}
+//
+// `f` runs in its own goroutine, and writes to the reader synchronously, so
+// the reader must be read until it reports io.EOF: until it is, `f` is
+// blocked, a deadlock if the caller then waits for the channel, say, and
+// the standard streams stay redirected, and so every other capture waits.
+// Closing the reader early abandons the rest of the output, after which
+// writes by `f` to the standard streams fail, but `f` carries on.
+//
+// The channel delivers exactly one error, nil if the capture succeeded,
+// and is then closed. If `f` panics then the panic is recovered, the reader
+// reports io.EOF, and the channel delivers the panic as an error.
+func CaptureOutputReader(f func()) (io.ReadCloser, <-chan error) {
+	reader, writer := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- captureTo(writer, f)
+		close(errc)
+		writer.Close()
+	}()
+	return reader, errc
+} // CaptureOutputReader
+
+// CaptureOutputToFile captures the merged standard output and standard error
+// of function `f`, as CaptureOutput does, but into a new temporary file in
+// the directory returned by os.TempDir, rather than into memory. This suits
+// captures too large to hold in memory. The path of the file is returned,
+// along with a function that removes the file:
+//
+//	```go
+//	path, cleanup, err := veil.CaptureOutputToFile(generateReport)
+//	if err != nil {
+//	    return err
+//	}
+//	defer cleanup()
+//	f, err := os.Open(path)
+//
+// The file has been written, synced, and closed by the time this function
+// returns. If `f` panics then the path and cleanup function are still
+// returned, along with the error, and the file holds the output that `f`
+// produced before it panicked. If the file cannot be created or written
+// then it is removed, and an empty path and nil cleanup are returned.
+func CaptureOutputToFile(f func()) (filePath string, cleanup func() error, err error) {
+	file, err := os.CreateTemp("", "veil-capture-*.log")
+	if err != nil {
+		return "", nil, err
+	}
+	filePath = file.Name()
+	err = captureTo(file, f)
+	if syncErr := errors.Join(file.Sync(), file.Close()); syncErr != nil {
+		IgnoreError(os.Remove(filePath))
+		return "", nil, errors.Join(err, syncErr)
+	}
+	return filePath, func() error {
+		return os.Remove(filePath)
+	}, err
+} // CaptureOutputToFile
+
+// CaptureCommand runs the command `name` with the given `args`, and
+// captures and returns its standard output and standard error separately,
+// along with its exit code.
+//
+// A command that ran, but exited with a non-zero exit code, is not an
+// error: `exitCode` is simply set. An error is only returned when the
+// command could not be run at all, e.g., because it was not found, in which
+// case `exitCode` is -1. `exitCode` is also -1 if the command was killed
+// by a signal.
+//
+// The command is run directly, as by exec.Command, so it does not affect
+// this program's standard streams and need not be serialized with the other
+// capture functions.
+func CaptureCommand(name string, args ...string) (stdout string, stderr string, exitCode int, err error) {
+	var outBuff, errBuff bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &outBuff
+	cmd.Stderr = &errBuff
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		exitCode = 0
+	case errors.As(err, &exitErr):
+		exitCode, err = exitErr.ExitCode(), nil
+	default:
+		exitCode = -1
+	}
+	return outBuff.String(), errBuff.String(), exitCode, err
+} // CaptureCommand
+
+// CaptureOutputContext captures and returns the merged standard output
+// and standard error of function `f`, as CaptureOutput does, but gives up
+// waiting for `f` when `ctx` is cancelled or its deadline passes.
+//
+// When the capture is abandoned the standard streams are restored, and
+// whatever `f` wrote before then is returned along with `ctx.Err()`.
+//
+// Go cannot stop a running goroutine, so an abandoned `f` keeps running
+// in the background. If it goes on writing to the now closed capture pipe
+// those writes fail, and the errors are silently discarded; writes through
+// `os.Stdout` or `os.Stderr` made after the streams are restored go to the
+// original streams (and are reported by the race detector, since nothing
+// can synchronize them with the restore).
+func CaptureOutputContext(ctx context.Context, f func()) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	defer restoreStreams()()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	os.Stdout = writer
+	os.Stderr = writer
+	out := drain(reader)
+	done := make(chan error, 1)
+	go func() {
+		done <- runRecovered(f, writer)
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		// closing the writer lets the reader reach end of file,
+		// and makes any further writes by `f` fail
+		writer.Close()
+	}
+	return <-out, err
+} // CaptureOutputContext
+
+// ErrIdleTimeout is returned, wrapped, by CaptureOutputIdleTimeout when
+// the captured function produces no output for too long.
+var ErrIdleTimeout = errors.New("no output before the idle timeout")
+
+// CaptureOutputIdleTimeout captures and returns the merged standard output
+// and standard error of function `f`, as CaptureOutput does, but gives up
+// waiting for `f` if it produces no output for `idle`, e.g., because
+// a command that streams its progress has hung:
+//
+//	```go
+//	output, err := veil.CaptureOutputIdleTimeout(runSync, 30*time.Second)
+//	if errors.Is(err, veil.ErrIdleTimeout) {
+//	    return fmt.Errorf("sync hung: %w", err)
+//	}
+//
+// The wait starts over whenever `f` writes something, so, unlike with
+// CaptureOutputContext, `f` can run for as long as it keeps writing. An `f`
+// that is working, but silently, for longer than `idle` is given up on, too.
+//
+// When the capture is given up on, whatever `f` wrote before then is
+// returned along with an error wrapping ErrIdleTimeout. As described for
+// CaptureOutputContext, `f` keeps running in the background, and its
+// further writes to the capture pipe fail.
+func CaptureOutputIdleTimeout(f func(), idle time.Duration) (string, error) {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	defer restoreStreams()()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	os.Stdout = writer
+	os.Stderr = writer
+	activity := make(activityWriter, 1)
+	out := drain(reader, activity)
+	done := make(chan error, 1)
+	go func() {
+		done <- runRecovered(f, writer)
+	}()
+	timer := time.NewTimer(idle)
+	defer timer.Stop()
+	for waiting := true; waiting; {
+		select {
+		case err = <-done:
+			waiting = false
+		case <-activity:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(idle)
+		case <-timer.C:
+			err = fmt.Errorf("%w of %v", ErrIdleTimeout, idle)
+			// as for CaptureOutputContext
+			writer.Close()
+			waiting = false
+		}
+	}
+	return <-out, err
+} // CaptureOutputIdleTimeout
+
+// activityWriter is an io.Writer that discards what is written to it,
+// but signals that something was written, without ever blocking.
+type activityWriter chan struct{}
+
+// Write signals that `p` was written, unless a signal is already pending.
+func (w activityWriter) Write(p []byte) (int, error) {
+	select {
+	case w <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+} // Write
+
+// CaptureOutputTee captures and returns the merged standard output and
+// standard error of function `f`, as CaptureOutput does, while also
+// echoing that output, as it is produced, to the original streams: what `f`
+// writes to `os.Stdout` is echoed to the original standard output, and what
+// it writes to `os.Stderr` to the original standard error.
+//
+// This is handy in tests: the output can be asserted on, and it is still
+// visible in the test log when debugging.
+//
+// Each stream is redirected to its own pipe, so that it can be echoed to
+// the right place. The output of the two streams is merged in the order it
+// is read from the pipes which, unlike with CaptureInterleaved, need not be
+// the order in which it was written.
+//
+// Everything that `f` wrote has been echoed by the time this function
+// returns.
+func CaptureOutputTee(f func()) (string, error) {
+	return captureToPooled(func(buff *bytes.Buffer) error {
+		return captureTee(buff, f)
+	})
+} // CaptureOutputTee
+
+// captureTee redirects standard output and standard error to a pipe each,
+// runs function `f`, and copies everything written to either pipe both to
+// `w` and to the stream that the pipe replaced.
+//
+// The capture is serialized with other captures, and a panic in `f` is
+// recovered and returned as an error. The copies have completed, and the
+// standard streams have been restored, when captureTee returns.
+func captureTee(w io.Writer, f func()) error {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	defer restoreStreams()()
+	outReader, outWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	errReader, errWriter, err := os.Pipe()
+	if err != nil {
+		outReader.Close()
+		outWriter.Close()
+		return err
+	}
+	merged := &lockedWriter{w: w}
+	var copies sync.WaitGroup
+	copies.Add(2)
+	tee := func(reader *os.File, original io.Writer) {
+		defer copies.Done()
+		// do nothing if an error occurs
+		// because there is nothing we can do
+		io.Copy(io.MultiWriter(merged, original), reader) // nolint:errcheck
+		reader.Close()
+	}
+	go tee(outReader, os.Stdout)
+	go tee(errReader, os.Stderr)
+	os.Stdout = outWriter
+	os.Stderr = errWriter
+	err = runRecovered(f, outWriter, errWriter)
+	copies.Wait()
+	return err
+} // captureTee
+
+// lockedWriter is an io.Writer that serializes the writes made to `w`,
+// so that several goroutines can write to it at once.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write writes `p` to the underlying writer, holding the lock.
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+} // Write
+
+// CaptureAllOutput captures and returns the merged standard output and
+// standard error of function `f`, as CaptureOutput does, together with
+// anything that `f` logs using the standard library's log package.
+//
+// The standard logger has its own writer, which is not affected by
+// redirecting `os.Stderr`, so it is temporarily redirected as well. Its
+// original writer is restored when this function returns, even if `f`
+// panics.
+func CaptureAllOutput(f func()) (string, error) {
+	return captureToPooled(func(buff *bytes.Buffer) error {
+		return captureTo(buff, f, redirectStdLog)
+	})
+} // CaptureAllOutput
+
+// CaptureOutputStripped captures and returns the merged standard output and
+// standard error of function `f`, as CaptureOutput does, but with any ANSI
+// escape sequences removed. This lets tests assert on the text written by
+// functions that use colors, such as zerolog's ConsoleWriter.
+//
+// Control sequences (CSI), such as "\x1b[31m" and "\x1b[2K", and operating
+// system commands (OSC), such as those that set the terminal title, are
+// removed. Ordinary text, including newlines, is left intact.
+func CaptureOutputStripped(f func()) (string, error) {
+	output, err := CaptureOutput(f)
+	return ansiEscapes.ReplaceAllString(output, ""), err
+} // CaptureOutputStripped
+
+// ansiEscapes matches ANSI CSI sequences, which are made up of parameter
+// bytes, intermediate bytes, and a final byte, and ANSI OSC sequences,
+// which end with either a BEL or a string terminator.
+var ansiEscapes = regexp.MustCompile(
+	`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)`)
+
+// CaptureOutputNormalized captures and returns the merged standard output
+// and standard error of function `f`, as CaptureOutput does, but with every
+// "\r\n" and every lone "\r" converted to "\n". This keeps assertions on the
+// output portable across platforms; use CaptureOutput for the exact bytes.
+func CaptureOutputNormalized(f func()) (string, error) {
+	output, err := CaptureOutput(f)
+	return lineEndings.Replace(output), err
+} // CaptureOutputNormalized
+
+// lineEndings converts "\r\n" and lone "\r" line endings to "\n".
+var lineEndings = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// CaptureStdout captures and returns the standard output of function `f`,
+// as CaptureOutput does, but leaves standard error alone, so that error
+// output still reaches the terminal, e.g., while debugging a test. It is
+// CaptureFile for `&os.Stdout`.
+func CaptureStdout(f func()) (string, error) {
+	return CaptureFile(&os.Stdout, f)
+} // CaptureStdout
+
+// CaptureStderr captures and returns the standard error of function `f`,
+// but leaves standard output alone, as CaptureStdout does the opposite.
+func CaptureStderr(f func()) (string, error) {
+	return CaptureFile(&os.Stderr, f)
+} // CaptureStderr
+
+// CaptureFile captures and returns everything written to the file that
+// `target` points to, while function `f` runs. `*target` is temporarily
+// replaced by the write end of a pipe, and is restored to its original
+// value when this function returns, even if `f` panics, in which case the
+// panic is returned as an error.
+//
+// `target` is a pointer to an *os.File variable, so that the variable
+// itself can be replaced, e.g., `&os.Stdout`, or the address of a package's
+// exported *os.File variable that a library writes to:
+//
+//	```go
+//	output, err := veil.CaptureFile(&mylib.Output, func() {
+//	    mylib.Report()
+//	})
+//
+// Only writes made through the variable are captured; code that kept its
+// own copy of the original *os.File still writes to it. The capture is
+// serialized with the other captures in this package.
+func CaptureFile(target **os.File, f func()) (string, error) {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	original := *target
+	defer func() {
+		*target = original
+	}()
+	*target = writer
+	out := drain(reader)
+	err = runRecovered(f, writer)
+	return <-out, err
+} // CaptureFile
+
+// CaptureLogEvents runs function `f` with the global log temporarily
+// replaced by one that logs, at the given logging `level`, to a buffer as
+// JSON, and returns the events logged by `f`, in order, each parsed into a
+// map from field name to value. This lets tests assert on the fields of the
+// events, e.g.:
+//
+//	```go
+//	events, err := veil.CaptureLogEvents(zerolog.DebugLevel, f)
+//	if err == nil && events[0]["level"] != "error" {
+//	    t.Errorf("level = %v", events[0]["level"])
+//	}
+//
+// The values are as decoded by encoding/json, so that numbers are float64
+// values, say. Each event has the usual `level`, `time`, `caller`, and
+// `message` fields, as appropriate.
+//
+// zerolog's global logging level is also set to `level` while `f` runs.
+// The previous global log and global level are restored when this function
+// returns, even if `f` panics, in which case the panic is returned as an
+// error along with the events logged before it.
+func CaptureLogEvents(level zerolog.Level, f func()) ([]map[string]any, error) {
+	logEventsMu.Lock()
+	defer logEventsMu.Unlock()
+	var buff bytes.Buffer
+	prevLevel := zerolog.GlobalLevel()
+	prevLogger := swapGlobalLogger(zerolog.New(zerolog.SyncWriter(&buff)).
+		Level(level).With().Timestamp().Caller().Logger())
+	zerolog.SetGlobalLevel(level)
+	err := runRecovered(f)
+	swapGlobalLogger(prevLogger)
+	zerolog.SetGlobalLevel(prevLevel)
+
+	var events []map[string]any
+	dec := json.NewDecoder(&buff)
+	for {
+		var event map[string]any
+		if decErr := dec.Decode(&event); decErr == io.EOF {
+			break
+		} else if decErr != nil {
+			return events, errors.Join(err, fmt.Errorf("cannot parse log event: %w", decErr))
+		}
+		events = append(events, event)
+	}
+	return events, err
+} // CaptureLogEvents
+
+// logEventsMu serializes CaptureLogEvents, which swaps the global log.
+var logEventsMu sync.Mutex
+
+// captureTo redirects both standard output and standard error to a pipe,
+// runs function `f`, and copies everything written to the pipe to `w`.
+//
+// Each of the `redirects` is called, once the standard streams have been
+// redirected, to redirect anything else to the pipe as well; the restore
+// functions that they return are called before captureTo returns.
+//
+// The capture is serialized with other captures, and a panic in `f` is
+// recovered and returned as an error. The copy to `w` has completed,
+// and the standard streams have been restored, when captureTo returns.
+//
+// Note that `w` is evaluated by the caller before the streams are
+// redirected, so `w` may safely refer to the original `os.Stdout`.
+func captureTo(w io.Writer, f func(), redirects ...redirect) error {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	defer restoreStreams()()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	os.Stdout = writer
+	os.Stderr = writer
+	for _, redirect := range redirects {
+		defer redirect(writer)()
+	}
+	copied := make(chan struct{})
+	go func() {
+		// do nothing if an error occurs
+		// because there is nothing we can do
+		io.Copy(w, reader) // nolint:errcheck
+		reader.Close()
+		close(copied)
+	}()
+	err = runRecovered(f, writer)
+	<-copied
+	return err
+} // captureTo
+
+// captureToPooled calls `capture` with an empty buffer from bufferPool,
+// and returns the buffer's contents, as a string, along with the error
+// returned by `capture`. The buffer is returned to the pool afterwards,
+// which is safe as the string is a copy of the buffer's contents.
+func captureToPooled(capture func(buff *bytes.Buffer) error) (string, error) {
+	buff := bufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		if buff.Cap() <= maxPooledBuffer {
+			buff.Reset()
+			bufferPool.Put(buff)
+		}
+	}()
+	err := capture(buff)
+	return buff.String(), err
+} // captureToPooled
+
+// bufferPool holds capture buffers for reuse, which saves creating, and
+// later collecting, a new buffer for every capture.
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// maxPooledBuffer is the capacity, in bytes, of the largest buffer that
+// is returned to bufferPool. Larger buffers are left to be collected, so
+// that one huge capture does not keep its memory for the life of the pool.
+const maxPooledBuffer = 1 << 20
+
+// restoreStreams takes a snapshot of `os.Stdin`, `os.Stdout`,
+// and `os.Stderr`, and returns a function that restores all three
+// to their snapshotted values. Capture functions call it on entry,
+// before swapping any stream:
+//
+//	```go
+//	defer restoreStreams()()
+//
+// so that every stream is restored however the function returns,
+// whether normally, early because of an error, or by panicking.
+func restoreStreams() (restore func()) {
+	stdin, stdout, stderr := os.Stdin, os.Stdout, os.Stderr
+	return func() {
+		os.Stdin, os.Stdout, os.Stderr = stdin, stdout, stderr
+	}
+} // restoreStreams
+
+// redirect is a function that redirects some output to `pipe`
+// during a capture, and returns a function that undoes the redirection.
+type redirect func(pipe *os.File) (restore func())
+
+// redirectStdLog is a redirect for the output of the standard library's
+// log package.
+func redirectStdLog(pipe *os.File) (restore func()) {
+	previous := stdlog.Writer()
+	stdlog.SetOutput(pipe)
+	return func() {
+		stdlog.SetOutput(previous)
+	}
+} // redirectStdLog
+
+// runRecovered runs function `f`, converting any panic into an error,
+// and then closes each of the `writers` whether or not `f` panicked.
+//
+// Closing the writers is what lets the goroutines reading from the
+// other ends of the pipes reach end of file, so it must always happen.
+func runRecovered(f func(), writers ...io.Closer) (err error) {
+	defer func() {
+		for _, writer := range writers {
+			writer.Close()
+		}
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("captured function panicked: %v", r)
+		}
+	}()
+	f()
+	return nil
+} // runRecovered
+
+// drain copies everything read from `reader` into a buffer, and also to
+// each of `tees`, in the background, and sends the buffer contents on the
+// returned channel when `reader` reaches end of file. The reader is closed
+// afterwards.
+func drain(reader *os.File, tees ...io.Writer) <-chan string {
+	out := make(chan string, 1)
+	go func() {
+		var buff bytes.Buffer
+		// do nothing if an error occurs
+		// because there is nothing we can do
+		io.Copy(io.MultiWriter(append([]io.Writer{&buff}, tees...)...), reader) // nolint:errcheck
+		reader.Close()
+		out <- buff.String()
+	}()
+	return out
+} // drain
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
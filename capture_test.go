@@ -0,0 +1,740 @@
+// File: capture_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestCaptureStreams(t *testing.T) {
+	stdout, stderr, err := CaptureStreams(func() {
+		for i := 0; i < 3; i++ {
+			fmt.Printf("out %d\n", i)
+			fmt.Fprintf(os.Stderr, "err %d\n", i)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "out 0\nout 1\nout 2\n"; stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+	if want := "err 0\nerr 1\nerr 2\n"; stderr != want {
+		t.Errorf("stderr = %q, want %q", stderr, want)
+	}
+} // TestCaptureStreams
+
+func TestCaptureStreamsLarge(t *testing.T) {
+	// more than a pipe holds, so both streams must be drained as they fill
+	stdout, stderr, err := CaptureStreams(func() {
+		printMegabyte()
+		os.Stderr.Write(megabyte) // nolint:errcheck
+	})
+	if err != nil || stdout != string(megabyte) || stderr != string(megabyte) {
+		t.Errorf("captured %d and %d bytes, %v, want %d bytes of each",
+			len(stdout), len(stderr), err, len(megabyte))
+	}
+} // TestCaptureStreamsLarge
+
+// TestCaptureRestoresStreams checks that the standard streams are restored
+// when a capture fails after they were swapped, here by `f` panicking after
+// replacing all three with a file of its own.
+func TestCaptureRestoresStreams(t *testing.T) {
+	other, err := os.Create(filepath.Join(t.TempDir(), "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	f := func() {
+		os.Stdin, os.Stdout, os.Stderr = other, other, other
+		panic("after the swap")
+	}
+	for name, capture := range map[string]func() error{
+		"CaptureOutput": func() error {
+			_, err := CaptureOutput(f)
+			return err
+		},
+		"CaptureStreams": func() error {
+			_, _, err := CaptureStreams(f)
+			return err
+		},
+		"RunWithIO": func() error {
+			_, _, err := RunWithIO("input\n", f)
+			return err
+		},
+	} {
+		stdin, stdout, stderr := os.Stdin, os.Stdout, os.Stderr
+		if err := capture(); err == nil {
+			t.Errorf("%s: err = nil, want the panic", name)
+		}
+		if os.Stdin != stdin || os.Stdout != stdout || os.Stderr != stderr {
+			os.Stdin, os.Stdout, os.Stderr = stdin, stdout, stderr
+			t.Errorf("%s did not restore the standard streams", name)
+		}
+	}
+} // TestCaptureRestoresStreams
+
+// megabyte is the output written by the large captures in benchmarks.
+var megabyte = bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+
+// printMegabyte prints a megabyte of output, as the function captured by
+// benchmarks of large captures.
+func printMegabyte() {
+	os.Stdout.Write(megabyte) // nolint:errcheck
+} // printMegabyte
+
+func BenchmarkCaptureOutputSized(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		hint int
+	}{
+		{name: "NoHint", hint: 0},
+		{name: "Hint", hint: len(megabyte)},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				output, err := CaptureOutputSized(printMegabyte, bm.hint)
+				if err != nil || len(output) != len(megabyte) {
+					b.Fatalf("captured %d bytes, %v", len(output), err)
+				}
+			}
+		})
+	}
+} // BenchmarkCaptureOutputSized
+
+// BenchmarkCaptureOutputPooled compares CaptureOutput, which reuses its
+// buffers through bufferPool, with a capture into a new buffer each time.
+func BenchmarkCaptureOutputPooled(b *testing.B) {
+	output := bytes.Repeat([]byte("0123456789abcdef"), 1<<10)
+	printOutput := func() {
+		os.Stdout.Write(output) // nolint:errcheck
+	}
+	for _, bm := range []struct {
+		name    string
+		capture func(f func()) (string, error)
+	}{
+		{name: "Pooled", capture: CaptureOutput},
+		{name: "Unpooled", capture: func(f func()) (string, error) {
+			return CaptureOutputSized(f, 0)
+		}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := bm.capture(printOutput); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+} // BenchmarkCaptureOutputPooled
+
+func TestCaptureOutputContext(t *testing.T) {
+	output, err := CaptureOutputContext(context.Background(), func() {
+		fmt.Print("to stdout, ")
+		fmt.Fprint(os.Stderr, "to stderr")
+	})
+	if err != nil || output != "to stdout, to stderr" {
+		t.Errorf("CaptureOutputContext() = %q, %v, want all of the output", output, err)
+	}
+} // TestCaptureOutputContext
+
+func TestCaptureOutputContextDeadline(t *testing.T) {
+	stdout, stderr := os.Stdout, os.Stderr
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	output, err := CaptureOutputContext(ctx, func() {
+		defer close(finished)
+		fmt.Print("partial")
+		// outlives the deadline, and writes nothing more once released
+		<-release
+	})
+	close(release)
+	<-finished
+	if output != "partial" {
+		t.Errorf("output = %q, want %q", output, "partial")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if os.Stdout != stdout || os.Stderr != stderr {
+		os.Stdout, os.Stderr = stdout, stderr
+		t.Error("the standard streams were not restored after the deadline")
+	}
+} // TestCaptureOutputContextDeadline
+
+func TestCaptureOutputTee(t *testing.T) {
+	dir := t.TempDir()
+	echoOut, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echoOut.Close()
+	echoErr, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echoErr.Close()
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = echoOut, echoErr
+	output, err := CaptureOutputTee(func() {
+		fmt.Print("to stdout\n")
+		fmt.Fprint(os.Stderr, "to stderr\n")
+	})
+	os.Stdout, os.Stderr = stdout, stderr
+	// each stream has its own pipe, so their order is not certain
+	if err != nil || (output != "to stdout\nto stderr\n" &&
+		output != "to stderr\nto stdout\n") {
+		t.Errorf("CaptureOutputTee() = %q, %v, want the output of both streams", output, err)
+	}
+	for name, want := range map[string]string{
+		echoOut.Name(): "to stdout\n",
+		echoErr.Name(): "to stderr\n",
+	} {
+		echo, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(echo) != want {
+			t.Errorf("echo to %s = %q, want %q", filepath.Base(name), echo, want)
+		}
+	}
+} // TestCaptureOutputTee
+
+// useStdLog sends the output of the standard library's log package to a
+// new buffer, without flags, until the end of the test.
+func useStdLog(t *testing.T) *bytes.Buffer {
+	writer, flags := stdlog.Writer(), stdlog.Flags()
+	t.Cleanup(func() {
+		stdlog.SetOutput(writer)
+		stdlog.SetFlags(flags)
+	})
+	var buff bytes.Buffer
+	stdlog.SetOutput(&buff)
+	stdlog.SetFlags(0)
+	return &buff
+} // useStdLog
+
+func TestCaptureAllOutput(t *testing.T) {
+	buff := useStdLog(t)
+	output, err := CaptureAllOutput(func() {
+		fmt.Print("to stdout\n")
+		stdlog.Print("to the log")
+		fmt.Fprint(os.Stderr, "to stderr\n")
+	})
+	if err != nil || output != "to stdout\nto the log\nto stderr\n" {
+		t.Errorf("CaptureAllOutput() = %q, %v, want all of the output", output, err)
+	}
+	if buff.Len() != 0 {
+		t.Errorf("log writer = %q, want nothing while capturing", buff.String())
+	}
+	stdlog.Print("after the capture")
+	if buff.String() != "after the capture\n" {
+		t.Errorf("log writer = %q, want the entry logged after the capture", buff.String())
+	}
+} // TestCaptureAllOutput
+
+func TestCaptureAllOutputPanic(t *testing.T) {
+	buff := useStdLog(t)
+	output, err := CaptureAllOutput(func() {
+		stdlog.Print("before the panic")
+		panic("boom")
+	})
+	if err == nil || output != "before the panic\n" {
+		t.Errorf("CaptureAllOutput() = %q, %v, want the output and an error", output, err)
+	}
+	if stdlog.Writer() != io.Writer(buff) {
+		t.Errorf("log.Writer() = %v, want the writer from before the capture", stdlog.Writer())
+	}
+	if flags := stdlog.Flags(); flags != 0 {
+		t.Errorf("log.Flags() = %d, want 0", flags)
+	}
+	if buff.Len() != 0 {
+		t.Errorf("log writer = %q, want nothing", buff.String())
+	}
+} // TestCaptureAllOutputPanic
+
+func TestRunWithIO(t *testing.T) {
+	stdout, stderr, err := RunWithIO("world\nignored\n", func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		if !scanner.Scan() {
+			fmt.Fprintln(os.Stderr, "no input")
+			return
+		}
+		fmt.Printf("hello, %s\n", scanner.Text())
+	})
+	if err != nil || stdout != "hello, world\n" || stderr != "" {
+		t.Errorf("RunWithIO() = %q, %q, %v, want the echoed line", stdout, stderr, err)
+	}
+} // TestRunWithIO
+
+func TestRunWithIOEndOfFile(t *testing.T) {
+	stdin := os.Stdin
+	stdout, _, err := RunWithIO("a\nb", func() {
+		// only returns once the input is closed
+		data, err := io.ReadAll(os.Stdin)
+		fmt.Printf("%q %v", data, err)
+	})
+	if err != nil || stdout != `"a\nb" <nil>` {
+		t.Errorf("RunWithIO() = %q, %v, want all of the input", stdout, err)
+	}
+	if os.Stdin != stdin {
+		t.Error("os.Stdin was not restored")
+	}
+} // TestRunWithIOEndOfFile
+
+func TestCaptureOutputBytes(t *testing.T) {
+	want := make([]byte, 256)
+	for i := range want {
+		want[i] = byte(i)
+	}
+	got, err := CaptureOutputBytes(func() {
+		os.Stdout.Write(want) // nolint:errcheck
+	})
+	if err != nil || !bytes.Equal(got, want) {
+		t.Errorf("CaptureOutputBytes() = %v, %v, want bytes 0x00 to 0xff", got, err)
+	}
+} // TestCaptureOutputBytes
+
+func TestCaptureOutputLimited(t *testing.T) {
+	const maxBytes = 100
+	type result struct {
+		output    string
+		truncated bool
+		err       error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		// far more than both the limit and the pipe buffer
+		r.output, r.truncated, r.err = CaptureOutputLimited(printMegabyte, maxBytes)
+		done <- r
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil || r.output != string(megabyte[:maxBytes]) || !r.truncated {
+			t.Errorf("CaptureOutputLimited() = %d bytes, %v, %v, want %d bytes, true, nil",
+				len(r.output), r.truncated, r.err, maxBytes)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("CaptureOutputLimited() did not return, the writer is blocked")
+	}
+	output, truncated, err := CaptureOutputLimited(func() { fmt.Print("short") }, maxBytes)
+	if err != nil || output != "short" || truncated {
+		t.Errorf("CaptureOutputLimited() = %q, %v, %v, want \"short\", false, nil",
+			output, truncated, err)
+	}
+} // TestCaptureOutputLimited
+
+func TestCaptureOutputLines(t *testing.T) {
+	var lines []string
+	output, err := CaptureOutputLines(func() {
+		fmt.Print("one\n")
+		fmt.Fprint(os.Stderr, "two\r\nthr")
+		fmt.Print("ee\npartial")
+	}, func(line string) {
+		lines = append(lines, line)
+	})
+	if err != nil || output != "one\ntwo\r\nthree\npartial" {
+		t.Errorf("CaptureOutputLines() = %q, %v, want all of the output", output, err)
+	}
+	if strings.Join(lines, "|") != "one|two|three" {
+		t.Errorf("lines = %q, want the three complete lines in order", lines)
+	}
+} // TestCaptureOutputLines
+
+func TestCaptureCommand(t *testing.T) {
+	stdout, stderr, exitCode, err := CaptureCommand("go", "version")
+	if err != nil || exitCode != 0 || !strings.HasPrefix(stdout, "go version ") || stderr != "" {
+		t.Errorf(`CaptureCommand("go", "version") = %q, %q, %d, %v, want the version`,
+			stdout, stderr, exitCode, err)
+	}
+} // TestCaptureCommand
+
+func TestCaptureCommandFailed(t *testing.T) {
+	stdout, stderr, exitCode, err := CaptureCommand("go", "no-such-command")
+	if err != nil || exitCode == 0 || exitCode == -1 || stdout != "" ||
+		!strings.Contains(stderr, "unknown command") {
+		t.Errorf(`CaptureCommand("go", "no-such-command") = %q, %q, %d, %v, want its exit code`,
+			stdout, stderr, exitCode, err)
+	}
+} // TestCaptureCommandFailed
+
+func TestCaptureCommandNotFound(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "no-such-command")
+	_, _, exitCode, err := CaptureCommand(name)
+	if err == nil || exitCode != -1 {
+		t.Errorf("CaptureCommand(%q) exit code = %d, err = %v, want -1 and an error",
+			name, exitCode, err)
+	}
+} // TestCaptureCommandNotFound
+
+func TestCaptureOutputStripped(t *testing.T) {
+	for _, tt := range []struct {
+		written, want string
+	}{
+		{written: "\x1b[31mred\x1b[0m text\n", want: "red text\n"},
+		{written: "\x1b[1;38;5;208mbold orange\x1b[m\n", want: "bold orange\n"},
+		{written: "\x1b[2Kcleared\x1b[1A\n", want: "cleared\n"},
+		{written: "\x1b]0;title\x07shown\n", want: "shown\n"},
+		{written: "\x1b]2;title\x1b\\shown\n", want: "shown\n"},
+		{written: "plain [31m text\n\n", want: "plain [31m text\n\n"},
+	} {
+		got, err := CaptureOutputStripped(func() { fmt.Print(tt.written) })
+		if err != nil || got != tt.want {
+			t.Errorf("CaptureOutputStripped(%q) = %q, %v, want %q",
+				tt.written, got, err, tt.want)
+		}
+	}
+} // TestCaptureOutputStripped
+
+func TestCaptureOutputNormalized(t *testing.T) {
+	written := "unix\nwindows\r\nold mac\rblank\r\n\r\n\rend"
+	got, err := CaptureOutputNormalized(func() { fmt.Print(written) })
+	if want := "unix\nwindows\nold mac\nblank\n\n\nend"; err != nil || got != want {
+		t.Errorf("CaptureOutputNormalized() = %q, %v, want %q", got, err, want)
+	}
+	if raw, err := CaptureOutput(func() { fmt.Print(written) }); err != nil || raw != written {
+		t.Errorf("CaptureOutput() = %q, %v, want the exact bytes %q", raw, err, written)
+	}
+} // TestCaptureOutputNormalized
+
+func TestCaptureLogEvents(t *testing.T) {
+	resetGlobalLog(t)
+	var buff bytes.Buffer
+	SetGlobalZerologToWriter(&buff, zerolog.WarnLevel, true)
+	events, err := CaptureLogEvents(zerolog.DebugLevel, func() {
+		log.Debug().Msg("first")
+		l := GlobalLogger()
+		l.Error().Int("code", 42).Str("user", "ann").Msg("second")
+		l.Trace().Msg("below the level")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %v, want two", events)
+	}
+	if events[0]["level"] != "debug" || events[0]["message"] != "first" {
+		t.Errorf("events[0] = %v, want the debug event", events[0])
+	}
+	e := events[1]
+	if e["level"] != "error" || e["code"] != 42.0 || e["user"] != "ann" {
+		t.Errorf("events[1] = %v, want the error event and its fields", e)
+	}
+	for _, key := range []string{"time", "caller"} {
+		if _, ok := e[key]; !ok {
+			t.Errorf("events[1] = %v, has no %s", e, key)
+		}
+	}
+	// the previous global log and level are restored
+	if zerolog.GlobalLevel() != zerolog.WarnLevel {
+		t.Errorf("global level = %v, want warn restored", zerolog.GlobalLevel())
+	}
+	l := GlobalLogger()
+	l.Warn().Msg("restored")
+	if previous := buff.String(); !strings.Contains(previous, "restored") ||
+		strings.Contains(previous, "second") {
+		t.Errorf("previous log = %q, want only the entry after the capture", previous)
+	}
+} // TestCaptureLogEvents
+
+func TestCaptureLogEventsPanic(t *testing.T) {
+	events, err := CaptureLogEvents(zerolog.InfoLevel, func() {
+		log.Info().Msg("before")
+		panic("oops")
+	})
+	if err == nil || len(events) != 1 || events[0]["message"] != "before" {
+		t.Errorf("CaptureLogEvents() = %v, %v, want the event and the panic", events, err)
+	}
+} // TestCaptureLogEventsPanic
+
+func TestCaptureOutputOf(t *testing.T) {
+	stdout := os.Stdout
+	output, result, err := CaptureOutputOf(func() int {
+		fmt.Println("computing")
+		return 42
+	})
+	if err != nil || output != "computing\n" || result != 42 {
+		t.Errorf("CaptureOutputOf() = %q, %d, %v, want the output and 42",
+			output, result, err)
+	}
+	if os.Stdout != stdout {
+		t.Error("os.Stdout was not restored")
+	}
+	output, result, err = CaptureOutputOf(func() int {
+		fmt.Print("partial")
+		panic("oops")
+	})
+	if err == nil || output != "partial" || result != 0 {
+		t.Errorf("CaptureOutputOf() = %q, %d, %v, want the output, 0 and the panic",
+			output, result, err)
+	}
+} // TestCaptureOutputOf
+
+// reportOutput is a *os.File variable that a library might write to,
+// as captured by TestCaptureFile.
+var reportOutput = os.Stderr
+
+func TestCaptureFile(t *testing.T) {
+	original := reportOutput
+	output, err := CaptureFile(&reportOutput, func() {
+		fmt.Fprint(reportOutput, "to the report")
+	})
+	if err != nil || output != "to the report" {
+		t.Errorf("CaptureFile() = %q, %v, want the report", output, err)
+	}
+	if reportOutput != original || os.Stderr != original {
+		t.Error("the variable was not restored, or another one was changed")
+	}
+	_, err = CaptureFile(&reportOutput, func() { panic("oops") })
+	if err == nil || reportOutput != original {
+		t.Errorf("CaptureFile() err = %v, want the panic, and the variable restored", err)
+	}
+} // TestCaptureFile
+
+func TestCaptureInterleaved(t *testing.T) {
+	var want strings.Builder
+	combined, err := CaptureInterleaved(func() {
+		for i := 0; i < 1000; i++ {
+			// alternate streams on every write
+			if i%2 == 0 {
+				fmt.Fprintf(os.Stdout, "out %d\n", i)
+			} else {
+				fmt.Fprintf(os.Stderr, "err %d\n", i)
+			}
+		}
+	})
+	for i := 0; i < 1000; i++ {
+		if i%2 == 0 {
+			fmt.Fprintf(&want, "out %d\n", i)
+		} else {
+			fmt.Fprintf(&want, "err %d\n", i)
+		}
+	}
+	if err != nil || combined != want.String() {
+		t.Errorf("CaptureInterleaved() lost the order of writes: %v", err)
+	}
+} // TestCaptureInterleaved
+
+func TestCaptureOutputWithFlush(t *testing.T) {
+	var w *bufio.Writer
+	output, err := CaptureOutputWithFlush(func() {
+		// buffered, and too little written to fill the buffer
+		w = bufio.NewWriter(os.Stdout)
+		fmt.Fprint(w, "buffered")
+	}, func() {
+		w.Flush()
+	})
+	if err != nil || output != "buffered" {
+		t.Errorf("CaptureOutputWithFlush() = %q, %v, want the flushed output", output, err)
+	}
+	output, err = CaptureOutput(func() {
+		w = bufio.NewWriter(os.Stdout)
+		fmt.Fprint(w, "lost")
+	})
+	if err != nil || output != "" {
+		t.Errorf("CaptureOutput() = %q, %v, want nothing without the flush", output, err)
+	}
+} // TestCaptureOutputWithFlush
+
+func TestCaptureOutputSlice(t *testing.T) {
+	for _, tt := range []struct {
+		name, written string
+		want          []string
+	}{
+		{name: "no output", written: "", want: nil},
+		{name: "one line", written: "one\n", want: []string{"one"}},
+		{name: "unterminated", written: "one", want: []string{"one"}},
+		{name: "empty line", written: "\n", want: []string{""}},
+		{
+			name:    "many lines",
+			written: "one\r\ntwo\n\nfour\n",
+			want:    []string{"one", "two", "", "four"},
+		},
+	} {
+		got, err := CaptureOutputSlice(func() { fmt.Print(tt.written) })
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: CaptureOutputSlice() = %q, %v, want %q",
+				tt.name, got, err, tt.want)
+		}
+	}
+} // TestCaptureOutputSlice
+
+func TestCaptureOutputReader(t *testing.T) {
+	output, errc := CaptureOutputReader(func() {
+		for i := 0; i < 4; i++ {
+			printMegabyte()
+		}
+	})
+	n, err := io.Copy(io.Discard, output)
+	if err != nil || n != 4*int64(len(megabyte)) {
+		t.Errorf("io.Copy() = %d, %v, want %d bytes", n, err, 4*len(megabyte))
+	}
+	output.Close()
+	if err := <-errc; err != nil {
+		t.Errorf("capture error = %v, want nil", err)
+	}
+	if _, open := <-errc; open {
+		t.Error("error channel is still open")
+	}
+
+	output, errc = CaptureOutputReader(func() {
+		fmt.Print("before")
+		panic("boom")
+	})
+	data, err := io.ReadAll(output)
+	if err != nil || string(data) != "before" {
+		t.Errorf("io.ReadAll() = %q, %v, want \"before\"", data, err)
+	}
+	output.Close()
+	if err := <-errc; err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("capture error = %v, want the panic", err)
+	}
+} // TestCaptureOutputReader
+
+func TestCaptureOutputToFile(t *testing.T) {
+	filePath, cleanup, err := CaptureOutputToFile(func() {
+		for i := 0; i < 3; i++ {
+			printMegabyte()
+		}
+		fmt.Fprint(os.Stderr, "done")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Repeat(string(megabyte), 3) + "done"
+	if string(data) != want {
+		t.Errorf("file holds %d bytes, want %d", len(data), len(want))
+	}
+	if err := cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filePath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file still exists after cleanup: %v", err)
+	}
+
+	filePath, cleanup, err = CaptureOutputToFile(func() {
+		fmt.Print("before")
+		panic("boom")
+	})
+	if err == nil || cleanup == nil {
+		t.Fatalf("CaptureOutputToFile() = %v, want the panic and a cleanup", err)
+	}
+	defer cleanup() // nolint:errcheck
+	if data, err := os.ReadFile(filePath); err != nil || string(data) != "before" {
+		t.Errorf("file = %q, %v, want the output before the panic", data, err)
+	}
+} // TestCaptureOutputToFile
+
+func TestCaptureStdoutAndStderr(t *testing.T) {
+	// the stream left alone goes to a file, rather than the terminal
+	other, err := os.Create(filepath.Join(t.TempDir(), "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	stdout, stderr := os.Stdout, os.Stderr
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+	os.Stdout, os.Stderr = other, other
+
+	output, err := CaptureStdout(func() {
+		fmt.Print("to stdout")
+		fmt.Fprint(os.Stderr, " [stderr during CaptureStdout]")
+	})
+	if err != nil || output != "to stdout" {
+		t.Errorf("CaptureStdout() = %q, %v, want only \"to stdout\"", output, err)
+	}
+	output, err = CaptureStderr(func() {
+		fmt.Print(" [stdout during CaptureStderr]")
+		fmt.Fprint(os.Stderr, "to stderr")
+	})
+	if err != nil || output != "to stderr" {
+		t.Errorf("CaptureStderr() = %q, %v, want only \"to stderr\"", output, err)
+	}
+	if os.Stdout != other || os.Stderr != other {
+		t.Error("the standard streams were not restored")
+	}
+	os.Stdout, os.Stderr = stdout, stderr
+
+	data, err := os.ReadFile(other.Name())
+	want := " [stderr during CaptureStdout] [stdout during CaptureStderr]"
+	if err != nil || string(data) != want {
+		t.Errorf("uncaptured output = %q, %v, want %q", data, err, want)
+	}
+} // TestCaptureStdoutAndStderr
+
+func TestCaptureOutputIdleTimeout(t *testing.T) {
+	output, err := CaptureOutputIdleTimeout(func() {
+		for i := 0; i < 10; i++ {
+			fmt.Print(".")
+			time.Sleep(30 * time.Millisecond)
+		}
+	}, 200*time.Millisecond)
+	if err != nil || output != ".........." {
+		t.Errorf("CaptureOutputIdleTimeout() = %q, %v, want all of the output",
+			output, err)
+	}
+
+	release, finished := make(chan struct{}), make(chan struct{})
+	start := time.Now()
+	output, err = CaptureOutputIdleTimeout(func() {
+		defer close(finished)
+		fmt.Print("started")
+		<-release // pauses for far longer than the idle timeout
+	}, 100*time.Millisecond)
+	elapsed := time.Since(start)
+	close(release)
+	<-finished
+	if !errors.Is(err, ErrIdleTimeout) || output != "started" {
+		t.Errorf("CaptureOutputIdleTimeout() = %q, %v, want \"started\" and ErrIdleTimeout",
+			output, err)
+	}
+	if elapsed > 5*time.Second {
+		t.Errorf("gave up after %v, want about the idle timeout", elapsed)
+	}
+} // TestCaptureOutputIdleTimeout
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
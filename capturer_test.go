@@ -0,0 +1,181 @@
+// File: capturer_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCapturerReuse(t *testing.T) {
+	var c Capturer
+	defer c.Close()
+	big := strings.Repeat("q", 300_000) // larger than a pipe's buffer
+	for _, want := range []string{"one\n", "", big, "two err\n"} {
+		got, err := c.Capture(func() {
+			fmt.Print(want)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Capture() output has %d bytes, want %d", len(got), len(want))
+		}
+	}
+} // TestCapturerReuse
+
+func TestCapturerPanic(t *testing.T) {
+	var c Capturer
+	defer c.Close()
+	got, err := c.Capture(func() {
+		fmt.Print("partial")
+		panic("boom")
+	})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Capture() err = %v, want the panic", err)
+	}
+	if got != "partial" {
+		t.Errorf("Capture() = %q, want %q", got, "partial")
+	}
+	if got, err = c.Capture(func() { fmt.Print("next") }); err != nil || got != "next" {
+		t.Errorf("Capture() after a panic = %q, %v, want %q", got, err, "next")
+	}
+} // TestCapturerPanic
+
+func TestCapturerClosedStdout(t *testing.T) {
+	var c Capturer
+	defer c.Close()
+	got, err := c.Capture(func() {
+		fmt.Print("y")
+		os.Stdout.Close()
+	})
+	if err == nil {
+		t.Error("Capture() err = nil, want an error for the closed pipe")
+	}
+	if got != "y" {
+		t.Errorf("Capture() = %q, want %q", got, "y")
+	}
+	if got, err = c.Capture(func() { fmt.Print("after") }); err != nil || got != "after" {
+		t.Errorf("Capture() after reopening = %q, %v, want %q", got, err, "after")
+	}
+} // TestCapturerClosedStdout
+
+func TestCapturerWritesAfterReturn(t *testing.T) {
+	var c Capturer
+	defer c.Close()
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		got, err := c.Capture(func() {
+			w := os.Stdout
+			fmt.Fprint(w, "start")
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						fmt.Fprint(w, "z")
+					}
+				}
+			}()
+			// lets the goroutine write before, and after, the sentinel
+			time.Sleep(10 * time.Millisecond)
+		})
+		if err != nil || !strings.HasPrefix(got, "start") {
+			t.Errorf("Capture() = %q, %v, want output starting %q", got, err, "start")
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Capture() blocked after the captured function returned")
+	}
+	close(stop)
+	wg.Wait()
+	got, err := c.Capture(func() { fmt.Print("end") })
+	if err != nil || !strings.HasSuffix(got, "end") || strings.Trim(got[:len(got)-3], "z") != "" {
+		t.Errorf("Capture() = %q, %v, want stray z's then %q", got, err, "end")
+	}
+} // TestCapturerWritesAfterReturn
+
+func TestCapturerBytesAfterSentinel(t *testing.T) {
+	var c Capturer
+	defer c.Close()
+	if err := c.open(); err != nil {
+		t.Fatal(err)
+	}
+	// a single read then returns the sentinel and the bytes after it
+	data := append([]byte("out"), c.sentinel...)
+	if _, err := c.writer.Write(append(data, "zz"...)); err != nil {
+		t.Fatal(err)
+	}
+	found := make(chan int, 1)
+	go func() {
+		end, _ := c.readToSentinel(c.reader)
+		found <- end
+	}()
+	select {
+	case end := <-found:
+		if end != len("out") {
+			t.Errorf("readToSentinel() = %d, want %d", end, len("out"))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("readToSentinel() missed a sentinel followed by more output")
+	}
+} // TestCapturerBytesAfterSentinel
+
+func BenchmarkCaptureOutput(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := CaptureOutput(printHello); err != nil {
+			b.Fatal(err)
+		}
+	}
+} // BenchmarkCaptureOutput
+
+func BenchmarkCapturer(b *testing.B) {
+	b.ReportAllocs()
+	var c Capturer
+	defer c.Close()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Capture(printHello); err != nil {
+			b.Fatal(err)
+		}
+	}
+} // BenchmarkCapturer
+
+// printHello prints a line, as the function captured by benchmarks.
+func printHello() {
+	fmt.Println("hello, world")
+}
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,150 @@
+// File: daily.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// SetGlobalZerologDaily sets up the global log, like SetGlobalZerologToFile
+// does, to a new log file for each day. The log files are in the directory
+// `dirPath`, which must already exist, and are named after `prefix` and the
+// local date, e.g., `app-2024-06-01.log` for a `prefix` of "app".
+//
+// The date is checked as each log entry is written, so the log switches to
+// the next day's file at local midnight, with the first entry written after
+// midnight being the first entry in the new file. This holds even if the
+// program starts just before midnight. Any earlier log files are left as
+// they are.
+//
+// The returned io.Closer closes the current log file. If the log file
+// cannot be opened then the global log is left unchanged.
+func SetGlobalZerologDaily(
+	dirPath string,
+	prefix string,
+	level zerolog.Level,
+) (io.Closer, error) {
+	return ConfigureGlobalZerolog(
+		WithDailyFiles(dirPath, prefix), WithLevel(level))
+} // SetGlobalZerologDaily
+
+// dailyLayout is the time.Time.Format layout of the date in the names of
+// daily log files.
+const dailyLayout = "2006-01-02"
+
+// dailyWriter is an io.WriteCloser that appends to a log file for the
+// current day, switching to a new log file when the date changes.
+type dailyWriter struct {
+	mu     sync.Mutex
+	dir    string
+	prefix string
+	perm   os.FileMode
+	now    func() time.Time
+	date   string
+	file   *os.File
+	closed bool
+}
+
+// newDailyWriter returns a dailyWriter for the log files named after
+// `prefix` in the directory `dir`, opening (or creating, with `perm`
+// permissions) the log file for the current day straight away. The
+// current day is told by `now`.
+func newDailyWriter(
+	dir string,
+	prefix string,
+	perm os.FileMode,
+	now func() time.Time,
+) (*dailyWriter, error) {
+	w := &dailyWriter{
+		dir:    dir,
+		prefix: prefix,
+		perm:   perm,
+		now:    now,
+	}
+	if err := w.open(w.now().Format(dailyLayout)); err != nil {
+		return nil, err
+	}
+	return w, nil
+} // newDailyWriter
+
+// Write writes `p` to the log file for the current day, first switching
+// to that file if the date has changed since the last write. Once the
+// writer has been closed, writing fails with os.ErrClosed.
+func (w *dailyWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	date := w.now().Format(dailyLayout)
+	if w.file != nil && date != w.date {
+		err = w.file.Close()
+		w.file = nil
+		if err != nil {
+			return 0, err
+		}
+	}
+	if w.file == nil {
+		if err = w.open(date); err != nil {
+			return 0, err
+		}
+	}
+	return w.file.Write(p)
+} // Write
+
+// Close closes the current log file.
+func (w *dailyWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+} // Close
+
+// open opens the log file for `date`.
+func (w *dailyWriter) open(date string) error {
+	f, err := openLogFile(w.fileName(date), w.perm)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.date = date
+	return nil
+} // open
+
+// fileName returns the name of the log file for `date`.
+func (w *dailyWriter) fileName(date string) string {
+	return filepath.Join(w.dir, w.prefix+"-"+date+".log")
+} // fileName
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,99 @@
+// File: daily_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDailyWriterMidnight(t *testing.T) {
+	dir := t.TempDir()
+	clock := time.Date(2024, 9, 20, 23, 59, 59, 0, time.UTC)
+	now := func() time.Time { return clock }
+	w, err := newDailyWriter(dir, "app", 0o644, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	for _, line := range []string{"first of the day\n", "last of the day\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock = clock.Add(2 * time.Second)
+	if _, err := w.Write([]byte("first after midnight\n")); err != nil {
+		t.Fatal(err)
+	}
+	for date, want := range map[string]string{
+		"2024-09-20": "first of the day\nlast of the day\n",
+		"2024-09-21": "first after midnight\n",
+	} {
+		data, err := os.ReadFile(w.fileName(date))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(data) != want {
+			t.Errorf("log file for %s = %q, want %q", date, data, want)
+		}
+	}
+} // TestDailyWriterMidnight
+
+func TestDailyWriterWriteAfterClose(t *testing.T) {
+	dir := t.TempDir()
+	now := func() time.Time {
+		return time.Date(2024, 9, 20, 12, 0, 0, 0, time.UTC)
+	}
+	w, err := newDailyWriter(dir, "app", 0o644, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("before close\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("daily after close\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close: err = %v, want os.ErrClosed", err)
+	}
+	if w.file != nil {
+		t.Error("Write after Close reopened the log file")
+	}
+	data, err := os.ReadFile(w.fileName("2024-09-20"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "before close\n" {
+		t.Errorf("log file = %q, want %q", got, "before close\n")
+	}
+	if strings.Contains(string(data), "after close") {
+		t.Error("an entry written after Close reached the log file")
+	}
+} // TestDailyWriterWriteAfterClose
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,95 @@
+// File: exit.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import "os"
+
+// ExitFunc is the function that this package calls to exit the program,
+// which is os.Exit unless it is temporarily replaced, e.g., by CaptureExit.
+//
+// Code that wants its exits to be testable with CaptureExit should exit
+// by calling ExitFunc, rather than os.Exit, too:
+//
+//	```go
+//	if err != nil {
+//	    fmt.Fprintln(os.Stderr, err)
+//	    veil.ExitFunc(2)
+//	}
+var ExitFunc = os.Exit
+
+// CaptureExit runs function `f`, with ExitFunc temporarily replaced, and
+// reports whether `f` called ExitFunc and, if so, the exit code that it
+// passed. The merged standard output and standard error of `f` are also
+// captured and returned, as they are by CaptureOutput.
+//
+// A call to ExitFunc stops `f` there and then, as os.Exit would, but by
+// panicking, so deferred functions in `f` do run. The panic is recovered,
+// and the test process keeps running:
+//
+//	```go
+//	code, called, output := veil.CaptureExit(func() {
+//	    runCLI([]string{"--bad-flag"})
+//	})
+//	if !called || code != 2 {
+//	    t.Errorf("exit code = %d, called = %v; output: %s", code, called, output)
+//	}
+//
+// Only calls to ExitFunc, made by the goroutine running `f`, are captured;
+// a direct call to os.Exit still ends the process. Any other panic in `f`
+// is passed on, once the capture has finished, as is any failure to capture
+// the output, as there is no error to return.
+func CaptureExit(f func()) (exitCode int, called bool, output string) {
+	var panicked any
+	output, err := CaptureOutput(func() {
+		previous := ExitFunc
+		ExitFunc = func(code int) {
+			panic(exitPanic{code: code})
+		}
+		defer func() {
+			ExitFunc = previous
+			if r := recover(); r != nil {
+				if exit, ok := r.(exitPanic); ok {
+					exitCode, called = exit.code, true
+				} else {
+					panicked = r
+				}
+			}
+		}()
+		f()
+	})
+	if panicked != nil {
+		panic(panicked)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return exitCode, called, output
+} // CaptureExit
+
+// exitPanic is the panic value that ExitFunc panics with while it is
+// replaced by CaptureExit, recording the exit `code`.
+type exitPanic struct {
+	code int
+}
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,55 @@
+// File: exit_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCaptureExit(t *testing.T) {
+	code, called, output := CaptureExit(func() {
+		fmt.Println("bad flag")
+		ExitFunc(2)
+		fmt.Println("not reached")
+	})
+	if !called || code != 2 {
+		t.Errorf("CaptureExit() = %d, %v, want 2, true", code, called)
+	}
+	if output != "bad flag\n" {
+		t.Errorf("CaptureExit() output = %q, want %q", output, "bad flag\n")
+	}
+
+	code, called, output = CaptureExit(func() { fmt.Println("fine") })
+	if called || code != 0 || output != "fine\n" {
+		t.Errorf("CaptureExit() = %d, %v, %q, want 0, false, %q",
+			code, called, output, "fine\n")
+	}
+	if reflect.ValueOf(ExitFunc).Pointer() != reflect.ValueOf(os.Exit).Pointer() {
+		t.Error("ExitFunc was not restored to os.Exit")
+	}
+} // TestCaptureExit
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
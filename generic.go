@@ -0,0 +1,120 @@
+// File: generic.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+// Must returns `v` if `err` is nil, otherwise it panics with `err`.
+//
+// It collapses the usual (value, error) pair returned by a function
+// into just the value, for code where an error cannot be handled anyway,
+// such as during initialization:
+//
+//	```go
+//	cwdLog := veil.Must(veil.FilePathInCwd("my-project.log"))
+//
+// The panic value is `err` itself, so a caller that recovers
+// can inspect it, e.g., by using errors.Is or errors.As.
+func Must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+} // Must
+
+// Must2 is like Must, but for functions that return
+// two values as well as an error.
+func Must2[A, B any](a A, b B, err error) (A, B) {
+	if err != nil {
+		panic(err)
+	}
+	return a, b
+} // Must2
+
+// OrDefault returns `v` if `err` is nil, otherwise it returns `fallback`.
+// It is a softer sibling of Must, for code that would rather carry on with
+// a default value than panic:
+//
+//	```go
+//	port, err := strconv.Atoi(os.Getenv("PORT"))
+//	port = veil.OrDefault(port, err, 8080)
+func OrDefault[T any](v T, err error, fallback T) T {
+	if err != nil {
+		return fallback
+	}
+	return v
+} // OrDefault
+
+// OrZero returns `v` if `err` is nil, otherwise it returns the zero value
+// of its type. Unlike OrDefault, it can wrap a call directly:
+//
+//	```go
+//	home := veil.OrZero(os.UserHomeDir())
+func OrZero[T any](v T, err error) T {
+	var zero T
+	return OrDefault(v, err, zero)
+} // OrZero
+
+// Coalesce returns the first of `vals` that is not the zero value of its
+// type, e.g., the first non-empty string, non-zero number, or non-nil
+// pointer. The zero value is returned if all of `vals` are zero, or if
+// there are no `vals`.
+func Coalesce[T comparable](vals ...T) T {
+	var zero T
+	for _, val := range vals {
+		if val != zero {
+			return val
+		}
+	}
+	return zero
+} // Coalesce
+
+// CoalesceFunc is like Coalesce, but for types that are not comparable,
+// such as slices and maps: `isZero` reports whether a value counts as zero.
+func CoalesceFunc[T any](isZero func(T) bool, vals ...T) T {
+	for _, val := range vals {
+		if !isZero(val) {
+			return val
+		}
+	}
+	var zero T
+	return zero
+} // CoalesceFunc
+
+// Ptr returns a pointer to a copy of `v`. It allows the address of
+// a literal, or of a function's result, to be taken in one expression:
+//
+//	```go
+//	cfg := Config{Name: veil.Ptr("demo"), Retries: veil.Ptr(3)}
+func Ptr[T any](v T) *T {
+	return &v
+} // Ptr
+
+// Deref returns the value that `p` points to,
+// or `fallback` if `p` is nil. It never panics.
+func Deref[T any](p *T, fallback T) T {
+	if p == nil {
+		return fallback
+	}
+	return *p
+} // Deref
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
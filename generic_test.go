@@ -0,0 +1,146 @@
+// File: generic_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestMust(t *testing.T) {
+	if got := Must(strconv.Atoi("42")); got != 42 {
+		t.Errorf(`Must(strconv.Atoi("42")) = %d, want 42`, got)
+	}
+	want := errors.New("no value")
+	r := panicValue(func() { Must(0, want) })
+	if err, ok := r.(error); !ok || !errors.Is(err, want) {
+		t.Errorf("Must(0, %v) panicked with %v, want %v", want, r, want)
+	}
+} // TestMust
+
+func TestMust2(t *testing.T) {
+	pair := func(err error) (string, int, error) { return "a", 1, err }
+	if a, b := Must2(pair(nil)); a != "a" || b != 1 {
+		t.Errorf(`Must2() = %q, %d, want "a", 1`, a, b)
+	}
+	want := errors.New("no pair")
+	r := panicValue(func() { Must2(pair(want)) })
+	if err, ok := r.(error); !ok || !errors.Is(err, want) {
+		t.Errorf("Must2() panicked with %v, want %v", r, want)
+	}
+} // TestMust2
+
+// panicValue calls `f` and returns the value that it panicked with,
+// or nil if it did not panic.
+func panicValue(f func()) (r any) {
+	defer func() { r = recover() }()
+	f()
+	return nil
+} // panicValue
+
+func TestCoalesce(t *testing.T) {
+	if got := Coalesce("", "", "b", "c"); got != "b" {
+		t.Errorf(`Coalesce("", "", "b", "c") = %q, want "b"`, got)
+	}
+	if got := Coalesce("", ""); got != "" {
+		t.Errorf(`Coalesce("", "") = %q, want ""`, got)
+	}
+	if got := Coalesce(0, 3, 4); got != 3 {
+		t.Errorf("Coalesce(0, 3, 4) = %d, want 3", got)
+	}
+	if got := Coalesce[int](); got != 0 {
+		t.Errorf("Coalesce() = %d, want 0", got)
+	}
+	a, b := 1, 2
+	if got := Coalesce(nil, &a, &b); got != &a {
+		t.Errorf("Coalesce(nil, &a, &b) = %p, want &a, %p", got, &a)
+	}
+	if got := Coalesce[*int](nil, nil); got != nil {
+		t.Errorf("Coalesce(nil, nil) = %p, want nil", got)
+	}
+} // TestCoalesce
+
+func TestCoalesceFunc(t *testing.T) {
+	isEmpty := func(s []int) bool { return len(s) == 0 }
+	got := CoalesceFunc(isEmpty, nil, []int{}, []int{1}, []int{2})
+	if len(got) != 1 || got[0] != 1 {
+		t.Errorf("CoalesceFunc() = %v, want [1]", got)
+	}
+	if got = CoalesceFunc(isEmpty, nil, []int{}); got != nil {
+		t.Errorf("CoalesceFunc() = %v, want nil", got)
+	}
+} // TestCoalesceFunc
+
+func TestPtr(t *testing.T) {
+	p := Ptr("text")
+	if p == nil || *p != "text" {
+		t.Fatalf("Ptr() = %v, want a pointer to \"text\"", p)
+	}
+	if q := Ptr("text"); q == p {
+		t.Error("Ptr() returned the same pointer twice")
+	}
+} // TestPtr
+
+func TestDeref(t *testing.T) {
+	tests := []struct {
+		name     string
+		p        *int
+		fallback int
+		want     int
+	}{
+		{name: "nil", p: nil, fallback: 7, want: 7},
+		{name: "zero", p: Ptr(0), fallback: 7, want: 0},
+		{name: "value", p: Ptr(3), fallback: 7, want: 3},
+	}
+	for _, tt := range tests {
+		if got := Deref(tt.p, tt.fallback); got != tt.want {
+			t.Errorf("%s: Deref() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if got := Deref((*string)(nil), "fallback"); got != "fallback" {
+		t.Errorf("Deref(nil) = %q, want \"fallback\"", got)
+	}
+} // TestDeref
+
+func TestOrDefault(t *testing.T) {
+	port, err := strconv.Atoi("9090")
+	if got := OrDefault(port, err, 8080); got != 9090 {
+		t.Errorf("OrDefault() = %d, want 9090", got)
+	}
+	port, err = strconv.Atoi("not a port")
+	if got := OrDefault(port, err, 8080); got != 8080 {
+		t.Errorf("OrDefault() = %d, want the fallback 8080", got)
+	}
+} // TestOrDefault
+
+func TestOrZero(t *testing.T) {
+	if got := OrZero("value", nil); got != "value" {
+		t.Errorf("OrZero() = %q, want \"value\"", got)
+	}
+	if got := OrZero("partial", errors.New("failed")); got != "" {
+		t.Errorf("OrZero() = %q, want \"\"", got)
+	}
+} // TestOrZero
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -2,11 +2,13 @@ module github.com/kjmjonline/veil
 
 go 1.22.2
 
-require github.com/rs/zerolog v1.33.0
+require (
+	github.com/mattn/go-isatty v0.0.20
+	github.com/pkg/errors v0.9.1
+	github.com/rs/zerolog v1.33.0
+)
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
 	golang.org/x/sys v0.24.0 // indirect
 )
@@ -0,0 +1,119 @@
+// File: maps.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Keys returns the keys of the map `m` as a new slice. The order of the
+// keys is unspecified, as Go's map iteration order is; use SortedKeys when
+// the order matters.
+//
+// A nil `m` gives a nil result.
+func Keys[K comparable, V any](m map[K]V) []K {
+	if m == nil {
+		return nil
+	}
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+} // Keys
+
+// Values returns the values of the map `m` as a new slice. The order of
+// the values is unspecified, as Go's map iteration order is.
+//
+// A nil `m` gives a nil result.
+func Values[K comparable, V any](m map[K]V) []V {
+	if m == nil {
+		return nil
+	}
+	values := make([]V, 0, len(m))
+	for _, value := range m {
+		values = append(values, value)
+	}
+	return values
+} // Values
+
+// SortedKeys returns the keys of the map `m` as a new slice, like Keys
+// does, but sorted into ascending order. This gives a deterministic order
+// in which to visit the map's entries:
+//
+//	```go
+//	for _, name := range veil.SortedKeys(scores) {
+//	    fmt.Println(name, scores[name])
+//	}
+func SortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := Keys(m)
+	slices.Sort(keys)
+	return keys
+} // SortedKeys
+
+// MergeMaps returns a new map holding the entries of all of `maps`, where
+// the value from a later map replaces that from an earlier map with the same
+// key. This suits layering settings, such as log fields, over defaults:
+//
+//	```go
+//	fields := veil.MergeMaps(defaultFields, envFields, flagFields)
+//
+// Nil maps are skipped. The result is never nil, even if all of `maps` are
+// nil, and none of `maps` is changed.
+func MergeMaps[K comparable, V any](maps ...map[K]V) map[K]V {
+	return MergeMapsFunc(func(_, incoming V) V {
+		return incoming
+	}, maps...)
+} // MergeMaps
+
+// MergeMapsFunc is like MergeMaps, except that when a later map has a key
+// that is already in the result, the value kept is the one returned by
+// `resolve`, given the `existing` value and the `incoming` one:
+//
+//	```go
+//	totals := veil.MergeMapsFunc(func(existing, incoming int) int {
+//	    return existing + incoming
+//	}, mondayCounts, tuesdayCounts)
+func MergeMapsFunc[K comparable, V any](
+	resolve func(existing, incoming V) V,
+	maps ...map[K]V,
+) map[K]V {
+	size := 0
+	for _, m := range maps {
+		size = max(size, len(m))
+	}
+	merged := make(map[K]V, size)
+	for _, m := range maps {
+		for key, incoming := range m {
+			if existing, ok := merged[key]; ok {
+				merged[key] = resolve(existing, incoming)
+			} else {
+				merged[key] = incoming
+			}
+		}
+	}
+	return merged
+} // MergeMapsFunc
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
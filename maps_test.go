@@ -0,0 +1,94 @@
+// File: maps_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestKeysAndValues(t *testing.T) {
+	m := map[string]int{"b": 2, "c": 3, "a": 1}
+	keys := Keys(m)
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"a", "b", "c"}) {
+		t.Errorf("Keys() = %q, want a, b, and c in some order", keys)
+	}
+	values := Values(m)
+	slices.Sort(values)
+	if !slices.Equal(values, []int{1, 2, 3}) {
+		t.Errorf("Values() = %v, want 1, 2, and 3 in some order", values)
+	}
+	if Keys[string, int](nil) != nil || Values[string, int](nil) != nil {
+		t.Error("Keys(nil) or Values(nil) is not nil")
+	}
+	if keys := Keys(map[string]int{}); keys == nil || len(keys) != 0 {
+		t.Errorf("Keys() of an empty map = %#v, want an empty slice", keys)
+	}
+} // TestKeysAndValues
+
+func TestSortedKeys(t *testing.T) {
+	m := map[int]string{}
+	for i := 20; i > 0; i-- {
+		m[i*7%20] = "v"
+	}
+	keys := SortedKeys(m)
+	if len(keys) != len(m) || !slices.IsSorted(keys) {
+		t.Errorf("SortedKeys() = %v, want all %d keys in order", keys, len(m))
+	}
+	got := SortedKeys(map[string]bool{"pear": true, "apple": true, "fig": true})
+	if !slices.Equal(got, []string{"apple", "fig", "pear"}) {
+		t.Errorf("SortedKeys() = %q, want apple, fig, pear", got)
+	}
+} // TestSortedKeys
+
+func TestMergeMaps(t *testing.T) {
+	defaults := map[string]string{"service": "app", "env": "dev"}
+	overrides := map[string]string{"env": "prod", "host": "web1"}
+	got := MergeMaps(defaults, nil, overrides)
+	want := map[string]string{"service": "app", "env": "prod", "host": "web1"}
+	if !maps.Equal(got, want) {
+		t.Errorf("MergeMaps() = %v, want %v", got, want)
+	}
+	if defaults["env"] != "dev" || len(defaults) != 2 {
+		t.Errorf("MergeMaps() changed its input to %v", defaults)
+	}
+	if got := MergeMaps[string, int](nil, nil); got == nil || len(got) != 0 {
+		t.Errorf("MergeMaps(nil, nil) = %#v, want an empty map", got)
+	}
+} // TestMergeMaps
+
+func TestMergeMapsFunc(t *testing.T) {
+	monday := map[string]int{"errors": 2, "warnings": 5}
+	tuesday := map[string]int{"errors": 3, "panics": 1}
+	got := MergeMapsFunc(func(existing, incoming int) int {
+		return existing + incoming
+	}, monday, nil, tuesday)
+	want := map[string]int{"errors": 5, "warnings": 5, "panics": 1}
+	if !maps.Equal(got, want) {
+		t.Errorf("MergeMapsFunc() = %v, want %v", got, want)
+	}
+} // TestMergeMapsFunc
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,597 @@
+// File: options.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/diode"
+)
+
+// LoggerOption is an option for ConfigureGlobalZerolog.
+//
+// Each option changes one aspect of how logging is set up; any aspect
+// that is not changed by an option keeps its default.
+type LoggerOption func(*loggerConfig)
+
+// WithFile makes the log be written to the file named `fileName`, which is
+// appended to if it already exists. By default the log is written to
+// standard error instead.
+func WithFile(fileName string) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.fileName = fileName
+	}
+} // WithFile
+
+// WithWriter makes the log be written to `w`, e.g., an in-memory buffer,
+// or a network connection, rather than to a file. The caller owns `w`: the
+// io.Closer returned by ConfigureGlobalZerolog does not close it, and `w`
+// must stay usable while it is the global log's writer. Writes to `w` are
+// not serialized, so if concurrent writes are not safe for `w` then wrap
+// it with zerolog.SyncWriter.
+func WithWriter(w io.Writer) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.writer = w
+	}
+} // WithWriter
+
+// WithFilePerm sets the permissions (before the umask) that the log file is
+// created with, if it does not already exist. The default is 0o644.
+func WithFilePerm(perm os.FileMode) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.filePerm = perm
+	}
+} // WithFilePerm
+
+// WithLevel sets the logging level. The default level is zerolog.InfoLevel.
+func WithLevel(level zerolog.Level) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.level = level
+	}
+} // WithLevel
+
+// WithJSON makes each log entry be written as a single line of JSON, rather
+// than in the default human-friendly console format. When the log is also
+// written to the console, by WithConsole, only the file is written as JSON.
+func WithJSON() LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.json = true
+	}
+} // WithJSON
+
+// WithConsole makes the log be written to standard error as well as to the
+// log file; see WithColor for which of the entries are colored.
+//
+// This option has no effect without WithFile or WithDailyFiles, since the
+// log is then written to standard error anyway.
+func WithConsole() LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.console = true
+	}
+} // WithConsole
+
+// WithColor forces human-friendly console formatted log entries to be
+// written in color if `color` is true, or without color if it is false.
+//
+// By default, log entries are only colored where they are written to a
+// terminal, as determined by IsTerminal, so that log files and output piped
+// to other programs are not cluttered with color escape sequences. Forcing
+// color on suits, e.g., log files that are only ever viewed with `less -R`.
+func WithColor(color bool) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.color = &color
+	}
+} // WithColor
+
+// WithoutCaller stops log entries from including the file and line number
+// where they were created. By default they are included.
+func WithoutCaller() LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.noCaller = true
+	}
+} // WithoutCaller
+
+// WithCallerSkip makes the file and line number in log entries be those of
+// the caller `skipFrames` stack frames further up than usual. With zero the
+// usual caller, as determined by zerolog's default, is used.
+//
+// This is for programs that log through their own helper functions: with
+// `skipFrames` set to one, for example, log entries show where a helper
+// was called rather than the line inside the helper.
+func WithCallerSkip(skipFrames int) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.callerSkip = skipFrames
+	}
+} // WithCallerSkip
+
+// WithTimeFormat sets the time format, as used by time.Time.Format, of the
+// timestamps shown in human-friendly console formatted log entries. The
+// default format is "Mon 02 Jan 2006, 15:04:05.000".
+//
+// The format of the timestamps in JSON log entries is not changed. A format
+// that formats a time as an empty string, such as "", is an error.
+func WithTimeFormat(timeFormat string) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.timeFormat = timeFormat
+	}
+} // WithTimeFormat
+
+// WithLevelFile makes log entries at `level` or above also be written to
+// a second log file, named `fileName`, e.g., to keep an "errors only" file
+// for alerting, while every log entry is still written to the main log.
+// The second file is opened like the main log file is, and its entries are
+// formatted in the same way.
+//
+// Log entries below the logging level set by WithLevel are not logged at
+// all, so `level` should be at least that level.
+func WithLevelFile(fileName string, level zerolog.Level) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.levelFile = fileName
+		cfg.levelFileLevel = level
+	}
+} // WithLevelFile
+
+// WithHook makes `hook` run for each log entry, just before the entry is
+// written, e.g., to count log entries, or to add fields to them. Hooks run
+// in the order that they are given, after any hooks that this package uses
+// itself, such as the one added by WithClock.
+func WithHook(hook zerolog.Hook) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.hooks = append(cfg.hooks, hook)
+	}
+} // WithHook
+
+// WithRotation makes the log file be rotated, as it is by
+// SetGlobalZerologRotating, whenever it would grow beyond `maxBytes` bytes,
+// keeping at most `maxBackups` backups. It requires WithFile.
+func WithRotation(maxBytes int64, maxBackups int) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.rotate = true
+		cfg.maxBytes = maxBytes
+		cfg.maxBackups = maxBackups
+	}
+} // WithRotation
+
+// WithCompressedBackups makes WithRotation gzip compress all but the most
+// recent backup of the log file, if `compress` is true. When `app.log` is
+// rotated, for example, `app.log.1` becomes `app.log.2.gz`, while later
+// backups keep their ".gz" suffix as they are shifted along.
+//
+// Backups are compressed in the background, so that logging does not wait
+// for them; a compressed backup left by an earlier run is replaced. This
+// option has no effect without WithRotation.
+func WithCompressedBackups(compress bool) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.compress = compress
+	}
+} // WithCompressedBackups
+
+// WithDailyFiles makes the log be written to a new log file for each day,
+// as it is by SetGlobalZerologDaily, in the directory `dirPath` and named
+// after `prefix`. It cannot be combined with WithFile or WithRotation.
+func WithDailyFiles(dirPath, prefix string) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.daily = true
+		cfg.dailyDir = dirPath
+		cfg.dailyPrefix = prefix
+	}
+} // WithDailyFiles
+
+// WithClock makes log entries be timestamped with the time returned by
+// `now`, rather than by time.Now, and makes WithDailyFiles use it to tell
+// the date. This lets tests freeze time, and so check the exact timestamps
+// of the log entries that they cause.
+//
+// Timestamps are formatted as they would be otherwise. Passing a nil `now`
+// restores the default of time.Now.
+func WithClock(now func() time.Time) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.clock = now
+	}
+} // WithClock
+
+// WithFields adds each of `fields`, as a string, to every log entry. This
+// suits fields that describe the program as a whole, such as its service
+// name, version, and host. The fields are added in the order of their keys.
+//
+// The values are always strings, so a value such as "1.0" is not turned
+// into a number. The map is copied, so later changes to it have no effect.
+func WithFields(fields map[string]string) LoggerOption {
+	return func(cfg *loggerConfig) {
+		if cfg.fields == nil {
+			cfg.fields = make(map[string]string, len(fields))
+		}
+		for key, value := range fields {
+			cfg.fields[key] = value
+		}
+	}
+} // WithFields
+
+// WithSampling makes only every `every`th log entry be written, starting
+// with the first, so that a hot code path cannot flood the log. With
+// `every` set to one, every log entry is written.
+//
+// Sampling drops log entries, whatever their level, so the log no longer
+// tells the whole story; use it only for logging that is too voluminous to
+// be kept in full. With WithBurst, sampling only applies beyond the burst.
+func WithSampling(every uint32) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.sample = true
+		cfg.sampleEvery = every
+	}
+} // WithSampling
+
+// WithBurst makes at most `burst` log entries be written in each `period`.
+// Beyond that, log entries are dropped, or are sampled if WithSampling is
+// also used, until the next period starts.
+//
+// As with WithSampling, log entries of every level are dropped; see it.
+func WithBurst(burst uint32, period time.Duration) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.burst = burst
+		cfg.burstPeriod = period
+	}
+} // WithBurst
+
+// WithBuffer makes log entries be written to the log file asynchronously,
+// through a buffer holding up to `size` entries, so that logging does not
+// wait for the file to be written. It requires WithFile or WithDailyFiles,
+// and a `size` of zero leaves the log unbuffered.
+//
+// When the buffer is full the oldest entries that have not yet been written
+// are dropped, rather than making logging wait, and the number of dropped
+// entries is reported on standard error. Closing the returned io.Closer
+// writes any buffered entries before closing the log file.
+func WithBuffer(size int) LoggerOption {
+	return func(cfg *loggerConfig) {
+		cfg.bufSize = size
+	}
+} // WithBuffer
+
+// ConfigureGlobalZerolog sets up the global log as described by `opts`,
+// and returns an io.Closer for the log file.
+//
+// Without any options, log entries at zerolog.InfoLevel and above are
+// written to standard error, in color if it is a terminal. Otherwise
+// logging is set up as it is by SetGlobalZerologToFile: entries have
+// RFC 3339 Nano timestamps, the file and line number where they were
+// created, and support stack traces.
+//
+// The returned io.Closer does nothing if there is no log file. If the log
+// cannot be set up, e.g., because the log file cannot be opened, then
+// the error is returned and the global log is left unchanged.
+func ConfigureGlobalZerolog(opts ...LoggerOption) (io.Closer, error) {
+	cfg := newLoggerConfig(opts)
+	logger, closer, err := cfg.build()
+	if err != nil {
+		return nil, err
+	}
+	installGlobalZerolog(logger, cfg.level, closer)
+	return closer, nil
+} // ConfigureGlobalZerolog
+
+// ReconfigureGlobalZerolog sets up the global log as described by `opts`,
+// as ConfigureGlobalZerolog does, while the program is running, and then
+// closes the log file of the previous global log. The new log file is, in
+// turn, closed by the next reconfiguration.
+//
+// The global log is swapped under a lock, so that GlobalLogger always
+// returns either the previous or the new logger, never a mixture of the
+// two. Code that may log during a reconfiguration must log through
+// GlobalLogger, or through the functions of this package, rather than
+// through zerolog's `log` package, whose `log.Logger` variable cannot be
+// read safely while it is being changed. Entries being written to a plain
+// log file (see WithFile) when it is closed are finished first, and entries
+// written through a copy of the previous logger after that are quietly
+// dropped, so no write fails.
+//
+// A typical use is to reopen the log file on SIGHUP, after an external tool
+// such as logrotate has moved it:
+//
+//	```go
+//	hup := make(chan os.Signal, 1)
+//	signal.Notify(hup, syscall.SIGHUP)
+//	go func() {
+//	    for range hup {
+//	        err := veil.ReconfigureGlobalZerolog(veil.WithFile("app.log"))
+//	        if err != nil {
+//	            l := veil.GlobalLogger()
+//	            l.Error().Err(err).Msg("cannot reopen the log file")
+//	        }
+//	    }
+//	}()
+//
+// If the new log cannot be set up then the error is returned and the global
+// log is left unchanged. Any error from closing the previous log file is
+// also returned, though the new global log is installed regardless.
+func ReconfigureGlobalZerolog(opts ...LoggerOption) error {
+	cfg := newLoggerConfig(opts)
+	logger, closer, err := cfg.build()
+	if err != nil {
+		return err
+	}
+	previous := installGlobalZerolog(logger, cfg.level, closer)
+	if err := previous.Close(); err != nil {
+		return fmt.Errorf("cannot close the previous log file: %w", err)
+	}
+	return nil
+} // ReconfigureGlobalZerolog
+
+// loggerConfig describes how logging is to be set up.
+type loggerConfig struct {
+	fileName       string
+	writer         io.Writer
+	filePerm       os.FileMode
+	level          zerolog.Level
+	json           bool
+	console        bool
+	color          *bool
+	noCaller       bool
+	callerSkip     int
+	timeFormat     string
+	rotate         bool
+	maxBytes       int64
+	maxBackups     int
+	compress       bool
+	daily          bool
+	dailyDir       string
+	dailyPrefix    string
+	bufSize        int
+	clock          func() time.Time
+	stampFormat    string
+	fields         map[string]string
+	sample         bool
+	sampleEvery    uint32
+	burst          uint32
+	burstPeriod    time.Duration
+	levelFile      string
+	levelFileLevel zerolog.Level
+	hooks          []zerolog.Hook
+}
+
+// newLoggerConfig returns the default logging configuration,
+// changed by each of `opts` in turn.
+func newLoggerConfig(opts []LoggerOption) *loggerConfig {
+	cfg := &loggerConfig{
+		filePerm:   0o644,
+		level:      zerolog.InfoLevel,
+		timeFormat: consoleTimeFormat,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+} // newLoggerConfig
+
+// build opens the log file, if any, and returns a logger as described by
+// the configuration, along with an io.Closer for the log file. The logging
+// level of the returned logger is not set.
+func (cfg *loggerConfig) build() (zerolog.Logger, io.Closer, error) {
+	var out io.Writer = os.Stderr
+	var closer io.Closer = nopCloser{}
+	hasFile := cfg.fileName != "" || cfg.daily || cfg.writer != nil
+	switch {
+	case cfg.writer != nil && (cfg.fileName != "" || cfg.daily):
+		return zerolog.Nop(), nil, errors.New(
+			"a log writer cannot be combined with a log file")
+	case cfg.daily && (cfg.fileName != "" || cfg.rotate):
+		return zerolog.Nop(), nil, errors.New(
+			"daily log files cannot be combined with a log file or rotation")
+	case cfg.fileName == "" && cfg.rotate:
+		return zerolog.Nop(), nil, errors.New("log rotation requires a log file")
+	case !hasFile && cfg.bufSize != 0:
+		return zerolog.Nop(), nil, errors.New("log buffering requires a log file")
+	case cfg.sample && cfg.sampleEvery == 0:
+		return zerolog.Nop(), nil, errors.New("invalid log sampling rate 0")
+	case cfg.burst > 0 && cfg.burstPeriod <= 0:
+		return zerolog.Nop(), nil, fmt.Errorf("invalid log burst period %v", cfg.burstPeriod)
+	case cfg.bufSize < 0:
+		return zerolog.Nop(), nil, fmt.Errorf("invalid log buffer size %d", cfg.bufSize)
+	case time.Unix(0, 0).UTC().Format(cfg.timeFormat) == "":
+		return zerolog.Nop(), nil, fmt.Errorf("invalid log time format %q", cfg.timeFormat)
+	case cfg.rotate:
+		w, err := newRotatingWriter(
+			cfg.fileName, cfg.filePerm, cfg.maxBytes, cfg.maxBackups, cfg.compress)
+		if err != nil {
+			return zerolog.Nop(), nil, err
+		}
+		out, closer = w, w
+	case cfg.daily:
+		w, err := newDailyWriter(
+			cfg.dailyDir, cfg.dailyPrefix, cfg.filePerm, cfg.now())
+		if err != nil {
+			return zerolog.Nop(), nil, err
+		}
+		out, closer = w, w
+	case cfg.fileName != "":
+		f, err := openLogFile(cfg.fileName, cfg.filePerm)
+		if err != nil {
+			return zerolog.Nop(), nil, err
+		}
+		lf := &logFile{file: f}
+		out, closer = lf, lf
+	case cfg.writer != nil:
+		out = cfg.writer
+	}
+	if cfg.bufSize > 0 {
+		if cfg.writer != nil {
+			// hides any Close method, so that the diode does not close it
+			out = struct{ io.Writer }{out}
+		}
+		// The diode writes any buffered entries, and then closes `out`,
+		// when it is closed.
+		dw := diode.NewWriter(out, cfg.bufSize, 0, reportDroppedEntries)
+		out, closer = dw, &logCloser{file: dw}
+	}
+	toConsole := cfg.console && hasFile
+	out = cfg.formatWriter(out)
+	if cfg.levelFile != "" {
+		f, err := openLogFile(cfg.levelFile, cfg.filePerm)
+		if err != nil {
+			IgnoreError(closer.Close())
+			return zerolog.Nop(), nil, err
+		}
+		out = zerolog.MultiLevelWriter(out, &zerolog.FilteredLevelWriter{
+			Writer: zerolog.LevelWriterAdapter{Writer: cfg.formatWriter(f)},
+			Level:  cfg.levelFileLevel,
+		})
+		closer = &logCloser{file: multiCloser{closer, f}}
+	}
+	if toConsole {
+		out = zerolog.MultiLevelWriter(cfg.consoleWriter(os.Stderr), out)
+	}
+	ctx := zerolog.New(out).With()
+	if cfg.clock == nil && cfg.stampFormat == "" {
+		ctx = ctx.Timestamp()
+	} else {
+		// in place of Timestamp, so that the fields keep their usual order
+		hook := clockHook{now: cfg.now(), format: cfg.stampFormat}
+		ctx = ctx.Logger().Hook(hook).With()
+	}
+	switch {
+	case cfg.noCaller:
+	case cfg.callerSkip != 0:
+		ctx = ctx.CallerWithSkipFrameCount(zerolog.CallerSkipFrameCount + cfg.callerSkip)
+	default:
+		ctx = ctx.Caller()
+	}
+	keys := make([]string, 0, len(cfg.fields))
+	for key := range cfg.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ctx = ctx.Str(key, cfg.fields[key])
+	}
+	logger := ctx.Logger()
+	for _, hook := range cfg.hooks {
+		logger = logger.Hook(hook)
+	}
+	if sampler := cfg.sampler(); sampler != nil {
+		logger = logger.Sample(sampler)
+	}
+	return logger, closer, nil
+} // build
+
+// sampler returns the zerolog sampler for the configuration,
+// or nil if log entries are not sampled.
+func (cfg *loggerConfig) sampler() zerolog.Sampler {
+	var sampler zerolog.Sampler
+	if cfg.sample {
+		sampler = &zerolog.BasicSampler{N: cfg.sampleEvery}
+	}
+	if cfg.burst > 0 {
+		sampler = &zerolog.BurstSampler{
+			Burst:       cfg.burst,
+			Period:      cfg.burstPeriod,
+			NextSampler: sampler,
+		}
+	}
+	return sampler
+} // sampler
+
+// now returns the function that tells the time for the configuration.
+func (cfg *loggerConfig) now() func() time.Time {
+	if cfg.clock == nil {
+		return time.Now
+	}
+	return cfg.clock
+} // now
+
+// formatWriter returns a writer that writes log entries
+// to `out` in the format of the configuration.
+func (cfg *loggerConfig) formatWriter(out io.Writer) io.Writer {
+	if cfg.json {
+		return out
+	}
+	return cfg.consoleWriter(out)
+} // formatWriter
+
+// consoleWriter returns a zerolog writer that writes human-friendly
+// console formatted entries to `out`, in color if WithColor forced color on,
+// or, by default, if `out` is a terminal.
+func (cfg *loggerConfig) consoleWriter(out io.Writer) zerolog.ConsoleWriter {
+	color := false
+	if cfg.color != nil {
+		color = *cfg.color
+	} else if f, ok := out.(*os.File); ok {
+		color = IsTerminal(f)
+	}
+	return zerolog.ConsoleWriter{
+		Out:        out,
+		NoColor:    !color,
+		TimeFormat: cfg.timeFormat,
+	}
+} // consoleWriter
+
+// clockHook is a zerolog.Hook that timestamps each log entry with the
+// time returned by `now`, just as zerolog's own timestamps are added.
+// The timestamp is written in `format` or, if that is empty, in zerolog's
+// process-wide timestamp format.
+type clockHook struct {
+	now    func() time.Time
+	format string
+}
+
+// Run adds the timestamp to the log entry `e`.
+func (h clockHook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	if h.format == "" {
+		e.Time(zerolog.TimestampFieldName, h.now())
+		return
+	}
+	e.Str(zerolog.TimestampFieldName, h.now().Format(h.format))
+} // Run
+
+// reportDroppedEntries reports, on standard error, that `missed` log
+// entries were dropped because the log buffer was full.
+func reportDroppedEntries(missed int) {
+	fmt.Fprintf(os.Stderr, "veil: log buffer full, dropped %d log entries\n", missed)
+} // reportDroppedEntries
+
+// multiCloser is an io.Closer that closes each of its io.Closers in turn.
+type multiCloser []io.Closer
+
+// Close closes each of the io.Closers, and returns all of their errors.
+func (mc multiCloser) Close() error {
+	var errs []error
+	for _, c := range mc {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+} // Close
+
+// nopCloser is an io.Closer that does nothing.
+type nopCloser struct{}
+
+// Close does nothing, and always succeeds.
+func (nopCloser) Close() error {
+	return nil
+} // Close
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
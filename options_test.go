@@ -0,0 +1,210 @@
+// File: options_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// escape starts every ANSI color escape sequence.
+const escape = "\x1b["
+
+func TestConsoleWriterColor(t *testing.T) {
+	resetGlobalLog(t)
+	var plain, colored bytes.Buffer
+	SetGlobalZerologToWriter(&plain, zerolog.InfoLevel, false)
+	l := GlobalLogger()
+	l.Info().Str("key", "value").Msg("to a buffer")
+	if !strings.Contains(plain.String(), "to a buffer") {
+		t.Errorf("buffer = %q, want the log entry", plain.String())
+	}
+	if strings.Contains(plain.String(), escape) {
+		t.Errorf("buffer = %q, has color escape sequences", plain.String())
+	}
+
+	if _, err := ConfigureGlobalZerolog(WithWriter(&colored), WithColor(true)); err != nil {
+		t.Fatal(err)
+	}
+	l = GlobalLogger()
+	l.Info().Msg("forced color")
+	if !strings.Contains(colored.String(), escape) {
+		t.Errorf("buffer = %q, want color escape sequences", colored.String())
+	}
+
+	logName := filepath.Join(t.TempDir(), "app.log")
+	closer, err := SetGlobalZerologToFileWithCloser(logName, zerolog.InfoLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = GlobalLogger()
+	l.Info().Msg("to a file")
+	SetGlobalZerologNop()
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "to a file") || strings.Contains(string(data), escape) {
+		t.Errorf("log file = %q, want the entry without color", data)
+	}
+} // TestConsoleWriterColor
+
+func TestWithClock(t *testing.T) {
+	resetGlobalLog(t)
+	frozen := time.Date(2024, 9, 20, 13, 14, 15, 123456789, time.UTC)
+	var buff bytes.Buffer
+	_, err := ConfigureGlobalZerolog(WithWriter(&buff), WithJSON(),
+		WithClock(func() time.Time { return frozen }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := GlobalLogger()
+	l.Info().Msg("frozen")
+	var entry map[string]any
+	if err := json.Unmarshal(buff.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-09-20T13:14:15.123456789Z"; entry["time"] != want {
+		t.Errorf("time = %v, want %s", entry["time"], want)
+	}
+
+	buff.Reset()
+	_, err = ConfigureGlobalZerolog(WithWriter(&buff), WithJSON(), WithClock(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = GlobalLogger()
+	before := time.Now()
+	l.Info().Msg("real time")
+	entry = nil
+	if err := json.Unmarshal(buff.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	stamp, err := time.Parse(time.RFC3339Nano, entry["time"].(string))
+	if err != nil || stamp.Before(before.Add(-time.Second)) {
+		t.Errorf("time = %v, %v, want the current time", entry["time"], err)
+	}
+} // TestWithClock
+
+func TestWithBurst(t *testing.T) {
+	resetGlobalLog(t)
+	var buff bytes.Buffer
+	for _, tt := range []struct {
+		opts []LoggerOption
+		want int
+	}{
+		{opts: []LoggerOption{WithBurst(5, time.Hour)}, want: 5},
+		{opts: []LoggerOption{WithBurst(5, time.Hour), WithSampling(10)}, want: 5 + 10},
+	} {
+		buff.Reset()
+		if _, err := ConfigureGlobalZerolog(append(tt.opts, WithWriter(&buff))...); err != nil {
+			t.Fatal(err)
+		}
+		l := GlobalLogger()
+		for i := 0; i < 105; i++ {
+			l.Info().Msg("burst")
+		}
+		if n := strings.Count(buff.String(), "\n"); n != tt.want {
+			t.Errorf("%d entries written, want %d", n, tt.want)
+		}
+	}
+} // TestWithBurst
+
+// errCloser is an io.Closer that always fails to close.
+type errCloser struct{}
+
+func (errCloser) Close() error { return errors.New("cannot close") }
+
+// TestReconfigureWhileLogging reconfigures the global log, again and
+// again, while many goroutines log through it; run with -race to check
+// that the swaps are safe.
+func TestReconfigureWhileLogging(t *testing.T) {
+	resetGlobalLog(t)
+	t.Setenv("VEIL_TEST_LEVEL", "not-a-level")
+	var writeErrs atomic.Int64
+	errorHandler := zerolog.ErrorHandler
+	t.Cleanup(func() { zerolog.ErrorHandler = errorHandler })
+	zerolog.ErrorHandler = func(error) { writeErrs.Add(1) }
+	dir := t.TempDir()
+	if err := ReconfigureGlobalZerolog(WithFile(filepath.Join(dir, "app-0.log")), WithJSON()); err != nil {
+		t.Fatal(err)
+	}
+	var wg, started sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		started.Add(1)
+		go func() {
+			defer wg.Done()
+			started.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				CloseLog(errCloser{})
+				LevelFromEnv("VEIL_TEST_LEVEL", zerolog.InfoLevel)
+				l := GlobalLogger()
+				l.Info().Msg("logging")
+			}
+		}()
+	}
+	started.Wait()
+	for i := 1; i <= 200; i++ {
+		globalMu.RLock()
+		previous := globalCloser.(*logFile)
+		globalMu.RUnlock()
+		logName := filepath.Join(dir, fmt.Sprintf("app-%d.log", i%2))
+		if err := ReconfigureGlobalZerolog(WithFile(logName), WithJSON()); err != nil {
+			t.Fatal(err)
+		}
+		previous.mu.RLock()
+		closed := previous.closed
+		previous.mu.RUnlock()
+		if !closed {
+			t.Fatalf("reconfiguration %d did not close the previous log file", i)
+		}
+	}
+	close(done)
+	wg.Wait()
+	if n := writeErrs.Load(); n != 0 {
+		t.Errorf("%d writes to the log failed", n)
+	}
+} // TestReconfigureWhileLogging
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
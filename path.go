@@ -0,0 +1,397 @@
+// File: path.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPathEscapesDir is returned, wrapped, when a file name would resolve
+// to a path outside of the directory that it was meant to be in.
+var ErrPathEscapesDir = errors.New("path escapes its directory")
+
+// FilePathInDir returns the full path of the file named `fileName`
+// in the directory `dir`. The path is cleaned, as by filepath.Clean.
+//
+// When `dir` is the current working directory this function returns the
+// same path as FilePathInCwd. Unlike FilePathInCwd, however, a `fileName`
+// that would escape `dir`, e.g., "../secret", is rejected with an error
+// that wraps ErrPathEscapesDir. An error is also returned if `dir` is empty.
+func FilePathInDir(dir, fileName string) (filePath string, err error) {
+	if dir == "" {
+		return "", errors.New("directory name is empty")
+	}
+	filePath = filepath.Join(dir, fileName)
+	if !isWithinDir(dir, filePath) {
+		return "", fmt.Errorf("%w: %q in %q", ErrPathEscapesDir, fileName, dir)
+	}
+	return filePath, nil
+} // FilePathInDir
+
+// RelPathFromCwd returns the path of `target` relative to the current
+// working directory, as by filepath.Rel, which is the inverse of
+// FilePathInCwd. It is handy for showing tidy paths to users:
+//
+//	```go
+//	rel, err := veil.RelPathFromCwd("/home/me/project/cmd/app/main.go")
+//	// rel is "cmd/app/main.go" in /home/me/project,
+//	// or "../project/cmd/app/main.go" in /home/me/other
+//
+// A relative `target` is taken to be relative to the current working
+// directory already, so it is only cleaned. An error is returned if
+// `target` cannot be made relative to the current working directory,
+// e.g., because it is on a different volume on Windows.
+func RelPathFromCwd(target string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(cwd, target)
+	}
+	return filepath.Rel(cwd, target)
+} // RelPathFromCwd
+
+// SafeJoin joins `base` and `rel`, as filepath.Join does, but returns an
+// error wrapping ErrPathEscapesDir if the resulting path is not within
+// `base`, e.g., because `rel` is "../../etc/passwd". This makes it safe
+// to use for writing files with user supplied names under a fixed
+// directory.
+//
+// An absolute `rel` is also rejected, rather than being treated as
+// relative to `base`, since it was evidently meant to be somewhere else.
+// Symbolic links are not resolved, so a link within `base` that points
+// outside of it is not detected.
+func SafeJoin(base, rel string) (string, error) {
+	if base == "" {
+		return "", errors.New("base directory name is empty")
+	}
+	if filepath.IsAbs(rel) {
+		return "", fmt.Errorf("%w: %q is absolute", ErrPathEscapesDir, rel)
+	}
+	joined := filepath.Join(base, rel)
+	if !isWithinDir(base, joined) {
+		return "", fmt.Errorf("%w: %q in %q", ErrPathEscapesDir, rel, base)
+	}
+	return joined, nil
+} // SafeJoin
+
+// FileExistsInCwd reports whether a file named `fileName` exists in the
+// current working directory. A directory named `fileName` counts as a file
+// that exists; use RegularFileExistsInCwd to only look for regular files.
+//
+// A file that does not exist is reported as false with a nil error, whereas
+// any other problem, e.g., permission being denied, is returned as an error.
+func FileExistsInCwd(fileName string) (exists bool, err error) {
+	var info fs.FileInfo
+	if info, err = statInCwd(fileName); err == nil {
+		exists = info != nil
+	}
+	return exists, err
+} // FileExistsInCwd
+
+// RegularFileExistsInCwd reports whether a regular file named `fileName`
+// exists in the current working directory. It is like FileExistsInCwd,
+// except that a directory (or other non-regular file) is reported as false.
+func RegularFileExistsInCwd(fileName string) (exists bool, err error) {
+	var info fs.FileInfo
+	if info, err = statInCwd(fileName); err == nil {
+		exists = info != nil && info.Mode().IsRegular()
+	}
+	return exists, err
+} // RegularFileExistsInCwd
+
+// EnsureDirInCwd makes sure that the directory `relPath`, relative to the
+// current working directory, exists, creating it and any missing parent
+// directories with permissions `perm` (before the umask) if necessary.
+// The full path of the directory is returned.
+//
+// It is not an error for the directory to exist already, but it is an error
+// if a file that is not a directory is in the way. As with FilePathInDir,
+// a `relPath` that escapes the current working directory is rejected.
+func EnsureDirInCwd(relPath string, perm os.FileMode) (dirPath string, err error) {
+	var cwd string
+	if cwd, err = os.Getwd(); err != nil {
+		return "", err
+	}
+	if dirPath, err = FilePathInDir(cwd, relPath); err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(dirPath, perm); err != nil {
+		return "", err
+	}
+	return dirPath, nil
+} // EnsureDirInCwd
+
+// TempFileInCwd creates a new temporary file in the current working
+// directory, opened for reading and writing, and returns it. It is like
+// os.CreateTemp, except that the file is created where FilePathInCwd
+// points rather than in the operating system's temporary directory.
+//
+// The file name is made by replacing the last "*" in `pattern` with a
+// random string, or by appending a random string if `pattern` has no "*".
+// The caller is responsible for removing the file once it is no longer
+// needed:
+//
+//	```go
+//	f, err := veil.TempFileInCwd("report-*.txt")
+//	if err != nil {
+//	    return err
+//	}
+//	defer os.Remove(f.Name())
+//	defer f.Close()
+func TempFileInCwd(pattern string) (*os.File, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(cwd, pattern)
+} // TempFileInCwd
+
+// TempDirInCwd creates a new temporary directory in the current working
+// directory, and returns its full path. It is like os.MkdirTemp, except
+// that the directory is created where FilePathInCwd points rather than
+// in the operating system's temporary directory.
+//
+// The directory name is made from `pattern` as it is by TempFileInCwd.
+// The caller is responsible for removing the directory, e.g., using
+// os.RemoveAll, once it is no longer needed.
+func TempDirInCwd(pattern string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(cwd, pattern)
+} // TempDirInCwd
+
+// WriteFileAtomic writes `data` to the file named `fileName`, like
+// os.WriteFile does, but atomically: `data` is first written to a temporary
+// file in the same directory, which is then renamed over `fileName`. Readers
+// therefore see either the original file or the new one, and never a
+// partially written file, even if the program dies part way through.
+//
+// The temporary file is in the same directory as `fileName` so that the
+// rename never has to cross file systems, which os.Rename cannot do. If
+// anything goes wrong then the temporary file is removed, and any existing
+// `fileName` is left intact.
+//
+// The file ends up with exactly `perm` permissions; unlike with
+// os.WriteFile, the umask is not applied, and the permissions of an
+// existing file are replaced.
+func WriteFileAtomic(fileName string, data []byte, perm os.FileMode) error {
+	return writeFileAtomic(fileName, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+} // WriteFileAtomic
+
+// CopyFile copies the file named `src` to a file named `dst`, which ends up
+// with the same permissions as `src`. The contents are streamed, rather than
+// read into memory, so large files can be copied.
+//
+// `dst` is written atomically, as by WriteFileAtomic, so readers of `dst`
+// see either the original file or the complete copy, and an existing `dst`
+// is left intact if anything goes wrong. An error is returned if `src` is a
+// directory, since only regular files are copied.
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer CloseIgnore(in)
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("cannot copy %q: is a directory", src)
+	}
+	return writeFileAtomic(dst, info.Mode().Perm(), func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+} // CopyFile
+
+// CopyFileInCwd copies the file named `srcName` to a file named `dstName`,
+// as CopyFile does, where both names are relative to the current working
+// directory. Names that would escape the current working directory, e.g.,
+// "../secret", are rejected with an error that wraps ErrPathEscapesDir.
+func CopyFileInCwd(srcName, dstName string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	src, err := FilePathInDir(cwd, srcName)
+	if err != nil {
+		return err
+	}
+	dst, err := FilePathInDir(cwd, dstName)
+	if err != nil {
+		return err
+	}
+	return CopyFile(src, dst)
+} // CopyFileInCwd
+
+// writeFileAtomic writes the file named `fileName` atomically, with exactly
+// `perm` permissions, as described for WriteFileAtomic: `write` writes the
+// contents to a temporary file, which is then renamed over `fileName`.
+func writeFileAtomic(
+	fileName string,
+	perm os.FileMode,
+	write func(w io.Writer) error,
+) (err error) {
+	dir, base := filepath.Split(fileName)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Chmod(perm); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), fileName)
+} // writeFileAtomic
+
+// ExpandTilde returns `path` with a leading "~" replaced by the current
+// user's home directory, so "~" and "~/logs/app.log" are expanded, much
+// as a shell would expand them. Other paths are returned unchanged.
+//
+// The "~user" form, naming some other user's home directory, is not
+// supported and is reported as an error.
+func ExpandTilde(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	rest := path[1:]
+	if rest != "" && rest[0] != '/' && rest[0] != filepath.Separator {
+		return "", fmt.Errorf("cannot expand %q: only ~ and ~/ are supported", path)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, rest), nil
+} // ExpandTilde
+
+// FindFileUpwards looks for a file named `fileName` in the current working
+// directory, then in its parent directory, and so on up to the root
+// directory, like git and go do to find their marker files. The full path
+// of the first file found is returned.
+//
+// If no such file is found then an error wrapping os.ErrNotExist is
+// returned.
+func FindFileUpwards(fileName string) (filePath string, err error) {
+	var cwd string
+	if cwd, err = os.Getwd(); err == nil {
+		filePath, err = FindFileUpwardsFrom(cwd, fileName)
+	}
+	return filePath, err
+} // FindFileUpwards
+
+// FindFileUpwardsFrom is like FindFileUpwards, except that the search
+// starts in the directory `startDir` rather than the current working
+// directory.
+func FindFileUpwardsFrom(startDir, fileName string) (string, error) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		filePath := filepath.Join(dir, fileName)
+		if _, err = os.Stat(filePath); err == nil {
+			return filePath, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			// the root directory is its own parent
+			return "", fmt.Errorf(
+				"%q not found in %q or any parent directory: %w",
+				fileName, startDir, os.ErrNotExist)
+		}
+		dir = parent
+	}
+} // FindFileUpwardsFrom
+
+// statInCwd returns the file info for the file named `fileName` in the
+// current working directory. If the file does not exist then both the
+// file info and the error are nil.
+func statInCwd(fileName string) (info fs.FileInfo, err error) {
+	var filePath string
+	if filePath, err = FilePathInCwd(fileName); err != nil {
+		return nil, err
+	}
+	if info, err = os.Stat(filePath); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return info, err
+} // statInCwd
+
+// isWithinDir reports whether `path` is `dir` itself, or is somewhere
+// underneath `dir`. Both are cleaned, which also normalizes their
+// separators, before `dir` is checked to be a prefix of `path`; a `dir`
+// of "." has no prefix, so instead `path` must be relative, and must not
+// lead up out of it.
+func isWithinDir(dir, path string) bool {
+	dir = filepath.Clean(dir)
+	path = filepath.Clean(path)
+	if path == dir {
+		return true
+	}
+	parent := ".." + string(filepath.Separator)
+	if dir == "." {
+		// joining "." with a name gives just the name, without "./"
+		return !filepath.IsAbs(path) && path != ".." && !strings.HasPrefix(path, parent)
+	}
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		// only the root directory already ends with a separator
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+} // isWithinDir
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
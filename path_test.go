@@ -0,0 +1,399 @@
+// File: path_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafeJoin(t *testing.T) {
+	tests := []struct {
+		base, rel string
+		want      string
+		escapes   bool
+	}{
+		{base: "/srv/data", rel: "x.log", want: "/srv/data/x.log"},
+		{base: "/srv/data", rel: "a/../b.log", want: "/srv/data/b.log"},
+		{base: "/srv/data", rel: "", want: "/srv/data"},
+		{base: "/srv/data", rel: "../secret", escapes: true},
+		{base: "/srv/data", rel: "../data2/x", escapes: true},
+		{base: "/srv/data", rel: "/etc/passwd", escapes: true},
+		{base: "/", rel: "etc/passwd", want: "/etc/passwd"},
+		{base: ".", rel: "x.log", want: "x.log"},
+		{base: ".", rel: "logs/x.log", want: "logs/x.log"},
+		{base: ".", rel: "..", escapes: true},
+		{base: ".", rel: "../x.log", escapes: true},
+		{base: "./", rel: "..x.log", want: "..x.log"},
+		{base: "logs", rel: "x.log", want: "logs/x.log"},
+		{base: "logs", rel: "../x.log", escapes: true},
+		{base: "..", rel: "x.log", want: "../x.log"},
+	}
+	for _, tt := range tests {
+		got, err := SafeJoin(filepath.FromSlash(tt.base), filepath.FromSlash(tt.rel))
+		if tt.escapes {
+			if !errors.Is(err, ErrPathEscapesDir) {
+				t.Errorf("SafeJoin(%q, %q) err = %v, want ErrPathEscapesDir",
+					tt.base, tt.rel, err)
+			}
+			continue
+		}
+		if err != nil || got != filepath.FromSlash(tt.want) {
+			t.Errorf("SafeJoin(%q, %q) = %q, %v, want %q",
+				tt.base, tt.rel, got, err, tt.want)
+		}
+	}
+	if _, err := SafeJoin("", "x"); err == nil {
+		t.Error(`SafeJoin("", "x") err = nil, want an error`)
+	}
+} // TestSafeJoin
+
+func TestFilePathInDir(t *testing.T) {
+	if got, err := FilePathInDir(".", "x.log"); err != nil || got != "x.log" {
+		t.Errorf(`FilePathInDir(".", "x.log") = %q, %v, want "x.log"`, got, err)
+	}
+	dir := t.TempDir()
+	want := filepath.Join(dir, "x.log")
+	if got, err := FilePathInDir(dir, "sub/../x.log"); err != nil || got != want {
+		t.Errorf("FilePathInDir() = %q, %v, want %q", got, err, want)
+	}
+	if _, err := FilePathInDir(dir, "../secret"); !errors.Is(err, ErrPathEscapesDir) {
+		t.Errorf("FilePathInDir() err = %v, want ErrPathEscapesDir", err)
+	}
+	if _, err := FilePathInDir("", "x.log"); err == nil {
+		t.Error(`FilePathInDir("", "x.log") err = nil, want an error`)
+	}
+} // TestFilePathInDir
+
+func TestFileExistsInCwd(t *testing.T) {
+	chdirTemp(t)
+	if err := os.WriteFile("file.txt", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("dir", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name            string
+		exists, regular bool
+	}{
+		{name: "missing.txt"},
+		{name: "file.txt", exists: true, regular: true},
+		{name: "dir", exists: true},
+	} {
+		if exists, err := FileExistsInCwd(tt.name); err != nil || exists != tt.exists {
+			t.Errorf("FileExistsInCwd(%q) = %v, %v, want %v",
+				tt.name, exists, err, tt.exists)
+		}
+		if regular, err := RegularFileExistsInCwd(tt.name); err != nil || regular != tt.regular {
+			t.Errorf("RegularFileExistsInCwd(%q) = %v, %v, want %v",
+				tt.name, regular, err, tt.regular)
+		}
+	}
+	// a stat error other than the file not existing is returned
+	if exists, err := FileExistsInCwd("file.txt/inside"); err == nil || exists {
+		t.Errorf("FileExistsInCwd() = %v, %v, want an error", exists, err)
+	}
+} // TestFileExistsInCwd
+
+// chdirTemp changes the current working directory to a new temporary
+// directory for the rest of the test `t`, and returns the directory's path.
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(previous); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return dir
+} // chdirTemp
+
+func TestEnsureDirInCwd(t *testing.T) {
+	cwd := chdirTemp(t)
+	want := filepath.Join(cwd, "a", "b", "c")
+	for i := 0; i < 2; i++ {
+		// a second call finds the directory already there
+		dirPath, err := EnsureDirInCwd(filepath.Join("a", "b", "c"), 0o755)
+		if err != nil || dirPath != want {
+			t.Fatalf("EnsureDirInCwd() = %q, %v, want %q", dirPath, err, want)
+		}
+		if info, err := os.Stat(want); err != nil || !info.IsDir() {
+			t.Fatalf("directory not created: %v", err)
+		}
+	}
+	if err := os.WriteFile("file", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EnsureDirInCwd("file", 0o755); err == nil {
+		t.Error("EnsureDirInCwd() err = nil, want an error for a file in the way")
+	}
+	if _, err := EnsureDirInCwd(filepath.Join("file", "sub"), 0o755); err == nil {
+		t.Error("EnsureDirInCwd() err = nil, want an error for a file in the way")
+	}
+	if _, err := EnsureDirInCwd("../escape", 0o755); !errors.Is(err, ErrPathEscapesDir) {
+		t.Errorf("EnsureDirInCwd() err = %v, want ErrPathEscapesDir", err)
+	}
+} // TestEnsureDirInCwd
+
+func TestFindFileUpwards(t *testing.T) {
+	root := chdirTemp(t)
+	deep := filepath.Join(root, "a", "b", "c")
+	if err := os.MkdirAll(deep, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		filepath.Join(root, "marker"),
+		filepath.Join(root, "a", "b", "near"),
+		filepath.Join(deep, "here"),
+	} {
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, want := range map[string]string{
+		"marker": filepath.Join(root, "marker"),
+		"near":   filepath.Join(root, "a", "b", "near"),
+		"here":   filepath.Join(deep, "here"),
+	} {
+		if got, err := FindFileUpwardsFrom(deep, name); err != nil || got != want {
+			t.Errorf("FindFileUpwardsFrom(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	got, err := FindFileUpwards("marker")
+	if err != nil || got != filepath.Join(root, "marker") {
+		t.Errorf("FindFileUpwards() = %q, %v, want the marker in the cwd", got, err)
+	}
+	_, err = FindFileUpwardsFrom(deep, "veil-test-no-such-marker")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("FindFileUpwardsFrom() err = %v, want os.ErrNotExist", err)
+	}
+} // TestFindFileUpwards
+
+func TestExpandTilde(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for path, want := range map[string]string{
+		"~":              home,
+		"~/":             home,
+		"~/logs/app.log": filepath.Join(home, "logs", "app.log"),
+		"/abs/app.log":   "/abs/app.log",
+		"rel/app.log":    "rel/app.log",
+		"rel/~/app.log":  "rel/~/app.log",
+		"":               "",
+	} {
+		if got, err := ExpandTilde(path); err != nil || got != want {
+			t.Errorf("ExpandTilde(%q) = %q, %v, want %q", path, got, err, want)
+		}
+	}
+	if _, err := ExpandTilde("~root/app.log"); err == nil {
+		t.Error(`ExpandTilde("~root/app.log") err = nil, want an error`)
+	}
+} // TestExpandTilde
+
+func TestTempFileInCwd(t *testing.T) {
+	cwd := chdirTemp(t)
+	f, err := TempFileInCwd("report-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		f.Close()
+		os.Remove(f.Name())
+	})
+	dir, name := filepath.Split(f.Name())
+	if filepath.Clean(dir) != cwd {
+		t.Errorf("temporary file %q is not in the cwd %q", f.Name(), cwd)
+	}
+	if !strings.HasPrefix(name, "report-") || !strings.HasSuffix(name, ".txt") ||
+		len(name) == len("report-.txt") {
+		t.Errorf("temporary file name = %q, want report-*.txt", name)
+	}
+	if _, err := f.WriteString("written"); err != nil {
+		t.Error(err)
+	}
+} // TestTempFileInCwd
+
+func TestTempDirInCwd(t *testing.T) {
+	cwd := chdirTemp(t)
+	dirPath, err := TempDirInCwd("work-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dirPath) })
+	if filepath.Dir(dirPath) != cwd || !strings.HasPrefix(filepath.Base(dirPath), "work-") {
+		t.Errorf("temporary directory = %q, want work-* in %q", dirPath, cwd)
+	}
+	if info, err := os.Stat(dirPath); err != nil || !info.IsDir() {
+		t.Errorf("temporary directory not created: %v", err)
+	}
+} // TestTempDirInCwd
+
+func TestWriteFileAtomic(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "config.json")
+	if err := WriteFileAtomic(fileName, []byte("version 1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(fileName, []byte("version 2"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(fileName); err != nil || string(data) != "version 2" {
+		t.Errorf("file = %q, %v, want \"version 2\"", data, err)
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Errorf("file mode = %v, want exactly 0o640", info.Mode().Perm())
+	}
+	assertOnlyFiles(t, filepath.Dir(fileName), "config.json")
+} // TestWriteFileAtomic
+
+func TestWriteFileAtomicInterrupted(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(fileName, []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	interrupted := errors.New("interrupted")
+	err := writeFileAtomic(fileName, 0o644, func(w io.Writer) error {
+		if _, err := w.Write([]byte("partial")); err != nil {
+			return err
+		}
+		// fail after writing, but before the rename
+		return interrupted
+	})
+	if !errors.Is(err, interrupted) {
+		t.Errorf("err = %v, want the interruption", err)
+	}
+	if data, err := os.ReadFile(fileName); err != nil || string(data) != "original" {
+		t.Errorf("file = %q, %v, want the original intact", data, err)
+	}
+	assertOnlyFiles(t, filepath.Dir(fileName), "config.json")
+} // TestWriteFileAtomicInterrupted
+
+// assertOnlyFiles fails the test `t` unless the directory `dir` holds
+// exactly the files `names`, e.g., because a temporary file was left.
+func assertOnlyFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	if strings.Join(got, " ") != strings.Join(names, " ") {
+		t.Errorf("files in %s = %q, want %q", dir, got, names)
+	}
+} // assertOnlyFiles
+
+func TestRelPathFromCwd(t *testing.T) {
+	cwd := chdirTemp(t)
+	tests := []struct {
+		target, want string
+	}{
+		{target: filepath.Join(cwd, "cmd", "app", "main.go"), want: "cmd/app/main.go"},
+		{
+			target: filepath.Join(filepath.Dir(cwd), "other", "main.go"),
+			want:   "../other/main.go",
+		},
+		{target: cwd, want: "."},
+		{target: "cmd/../docs/./README.md", want: "docs/README.md"},
+	}
+	for _, tt := range tests {
+		got, err := RelPathFromCwd(tt.target)
+		if err != nil || got != filepath.FromSlash(tt.want) {
+			t.Errorf("RelPathFromCwd(%q) = %q, %v, want %q", tt.target, got, err, tt.want)
+		}
+	}
+} // TestRelPathFromCwd
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src.dat"), filepath.Join(dir, "dst.dat")
+	if err := os.WriteFile(src, megabyte, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(src, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(dst); err != nil || !bytes.Equal(data, megabyte) {
+		t.Errorf("copy = %d bytes, %v, want the %d bytes of the source",
+			len(data), err, len(megabyte))
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o750 {
+		t.Errorf("copy mode = %v, want the source's 0o750", info.Mode().Perm())
+	}
+	assertOnlyFiles(t, dir, "dst.dat", "src.dat")
+
+	if err := CopyFile(dir, filepath.Join(dir, "copy")); err == nil ||
+		!strings.Contains(err.Error(), "is a directory") {
+		t.Errorf("CopyFile(directory) = %v, want an error", err)
+	}
+	assertOnlyFiles(t, dir, "dst.dat", "src.dat")
+} // TestCopyFile
+
+func TestCopyFileInCwd(t *testing.T) {
+	cwd := chdirTemp(t)
+	if err := os.WriteFile("a.txt", []byte("text"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyFileInCwd("a.txt", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(cwd, "b.txt")); err != nil || string(data) != "text" {
+		t.Errorf("copy = %q, %v, want \"text\"", data, err)
+	}
+	if err := CopyFileInCwd("a.txt", "../b.txt"); !errors.Is(err, ErrPathEscapesDir) {
+		t.Errorf("CopyFileInCwd(\"../b.txt\") = %v, want ErrPathEscapesDir", err)
+	}
+} // TestCopyFileInCwd
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,316 @@
+// File: rotate.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// SetGlobalZerologRotating sets up the global log, like
+// SetGlobalZerologToFile does, to a file named `logName` that is rotated
+// whenever it would grow beyond `maxBytes` bytes.
+//
+// On rotation `logName` is renamed to `logName.1`, any existing
+// `logName.1` is renamed to `logName.2`, and so on, keeping at most
+// `maxBackups` backups; the oldest backup is discarded. A fresh `logName`
+// is then opened. With `maxBackups` equal to zero no backups are kept and
+// the log file is simply started afresh.
+//
+// The size is checked before each log entry is written, and each entry is
+// written whole, so a rotation never splits a log entry across two files.
+// A single entry that is larger than `maxBytes` is still written, alone,
+// to its own file.
+//
+// The returned io.Closer closes the current log file. If the log file
+// cannot be opened then the global log is left unchanged. Use
+// ConfigureGlobalZerolog with WithRotation and WithCompressedBackups
+// to compress older backups.
+func SetGlobalZerologRotating(
+	logName string,
+	level zerolog.Level,
+	maxBytes int64,
+	maxBackups int,
+) (io.Closer, error) {
+	return ConfigureGlobalZerolog(
+		WithFile(logName), WithLevel(level), WithRotation(maxBytes, maxBackups))
+} // SetGlobalZerologRotating
+
+// rotatingWriter is an io.WriteCloser that appends to a log file,
+// rotating the file once it would grow beyond a maximum size.
+type rotatingWriter struct {
+	mu         sync.Mutex
+	name       string
+	perm       os.FileMode
+	maxBytes   int64
+	maxBackups int
+	compress   bool
+	file       *os.File
+	size       int64
+	closed     bool
+	pending    sync.WaitGroup // background compression of a backup
+}
+
+// newRotatingWriter returns a rotatingWriter for the log file named
+// `name`, opening (or creating, with `perm` permissions) the file
+// straight away. If `compress` is true then all but the most recent
+// backup are gzip compressed.
+func newRotatingWriter(
+	name string,
+	perm os.FileMode,
+	maxBytes int64,
+	maxBackups int,
+	compress bool,
+) (*rotatingWriter, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("invalid maximum log size %d", maxBytes)
+	}
+	if maxBackups < 0 {
+		return nil, fmt.Errorf("invalid number of log backups %d", maxBackups)
+	}
+	w := &rotatingWriter{
+		name:       name,
+		perm:       perm,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+		compress:   compress,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+} // newRotatingWriter
+
+// Write writes `p` to the log file, first rotating the file if writing
+// `p` would make it larger than the maximum size. Once the writer has been
+// closed, writing fails with os.ErrClosed.
+func (w *rotatingWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.file != nil && w.size > 0 && w.size+int64(len(p)) > w.maxBytes {
+		if err = w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	if w.file == nil {
+		// a previous rotation could not reopen the log file
+		if err = w.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err = w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+} // Write
+
+// Close closes the current log file, once any backup that is being
+// compressed has been compressed.
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending.Wait()
+	w.closed = true
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+} // Close
+
+// open opens the log file and records its current size.
+func (w *rotatingWriter) open() error {
+	f, err := openLogFile(w.name, w.perm)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+} // open
+
+// rotate closes the log file, shifts the backups along by one,
+// and opens a fresh log file. The caller must hold `w.mu`.
+//
+// When compressing, the backup that was the most recent is compressed
+// in the background once it has been shifted along; a later rotation
+// waits for that to finish before shifting the backups again. A backup
+// that could not be compressed is left uncompressed, shifted along in that
+// form, and compressed again at the next rotation, so it is never lost.
+func (w *rotatingWriter) rotate() error {
+	w.pending.Wait()
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	if w.maxBackups == 0 {
+		if err := os.Remove(w.name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return w.open()
+	}
+	for i := w.maxBackups - 1; i >= 1; i-- {
+		if err := w.shiftBackup(i); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(w.name, w.backupName(1)); err != nil {
+		return err
+	}
+	if w.compress {
+		// the most recent backup was shifted along uncompressed, as were
+		// any that could not be compressed before
+		for i := 2; i <= w.maxBackups; i++ {
+			if _, err := os.Lstat(w.plainBackupName(i)); err == nil {
+				w.pending.Add(1)
+				go w.compressBackup(i)
+			}
+		}
+	}
+	return w.open()
+} // rotate
+
+// shiftBackup renames the `i`th backup of the log file to be the `i+1`th,
+// replacing that backup.
+//
+// When compressing, the `i`th backup may be compressed or, if it could not
+// be compressed, not, and is shifted along in the same form. The `i+1`th
+// backup is removed first, in either form, so that it is not left behind
+// alongside the shifted backup.
+func (w *rotatingWriter) shiftBackup(i int) error {
+	if !w.compress {
+		err := os.Rename(w.backupName(i), w.backupName(i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	renames := [][2]string{{w.plainBackupName(i), w.plainBackupName(i + 1)}}
+	if i >= 2 {
+		renames = append(renames, [2]string{w.backupName(i), w.backupName(i + 1)})
+	}
+	var found [][2]string
+	for _, rename := range renames {
+		if _, err := os.Lstat(rename[0]); err == nil {
+			found = append(found, rename)
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	for _, name := range []string{w.plainBackupName(i + 1), w.backupName(i + 1)} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	for _, rename := range found {
+		if err := os.Rename(rename[0], rename[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+} // shiftBackup
+
+// compressBackup gzip compresses the `i`th backup of the log file, which
+// is not yet compressed, replacing any compressed backup of the same name
+// left by an earlier run. Any error is reported on standard error, as
+// there is no caller to return it to, and the backup is left uncompressed.
+func (w *rotatingWriter) compressBackup(i int) {
+	defer w.pending.Done()
+	if err := gzipFile(w.plainBackupName(i), w.backupName(i)); err != nil {
+		fmt.Fprintf(os.Stderr, "veil: cannot compress log backup: %v\n", err)
+	}
+} // compressBackup
+
+// backupName returns the name of the `i`th backup of the log file. When
+// compressing, all but the first backup have a ".gz" suffix.
+func (w *rotatingWriter) backupName(i int) string {
+	if w.compress && i >= 2 {
+		return w.plainBackupName(i) + ".gz"
+	}
+	return w.plainBackupName(i)
+} // backupName
+
+// plainBackupName returns the name of the `i`th backup of the log file,
+// without any ".gz" suffix.
+func (w *rotatingWriter) plainBackupName(i int) string {
+	return fmt.Sprintf("%s.%d", w.name, i)
+} // plainBackupName
+
+// gzipFile compresses the file named `src` to a file named `dst`, and then
+// removes `src`. The compressed file is written under a temporary name and
+// renamed to `dst` once complete, so an existing `dst` is only replaced by
+// a complete file.
+func gzipFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+} // gzipFile
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,249 @@
+// File: rotate_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRotatingWriter(t *testing.T) {
+	logName := filepath.Join(t.TempDir(), "app.log")
+	// two entries fit in the log file, and two backups are kept
+	w, err := newRotatingWriter(logName, 0o644, 2*int64(len("entry 1\n")), 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	wants := []map[string]string{
+		// written to the log file, before there is anything to rotate
+		1: {logName: "entry 1\n"},
+		2: {logName: "entry 1\nentry 2\n"},
+		// the log file is full, so rotates to the first backup
+		3: {logName: "entry 3\n", logName + ".1": "entry 1\nentry 2\n"},
+		// the first backup is shifted along to the second
+		5: {
+			logName:        "entry 5\n",
+			logName + ".1": "entry 3\nentry 4\n",
+			logName + ".2": "entry 1\nentry 2\n",
+		},
+		// the oldest backup is dropped
+		7: {
+			logName:        "entry 7\n",
+			logName + ".1": "entry 5\nentry 6\n",
+			logName + ".2": "entry 3\nentry 4\n",
+		},
+	}
+	for i := 1; i < len(wants); i++ {
+		if _, err := fmt.Fprintf(w, "entry %d\n", i); err != nil {
+			t.Fatal(err)
+		}
+		for name, want := range wants[i] {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				t.Errorf("after entry %d: %v", i, err)
+				continue
+			}
+			if string(data) != want {
+				t.Errorf("after entry %d: %s = %q, want %q", i, name, data, want)
+			}
+		}
+	}
+	if _, err := os.Stat(logName + ".3"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("%s exists, err = %v", logName+".3", err)
+	}
+} // TestRotatingWriter
+
+func TestRotatingWriterWriteAfterClose(t *testing.T) {
+	resetGlobalLog(t)
+	logName := filepath.Join(t.TempDir(), "r.log")
+	closer, err := SetGlobalZerologRotating(logName, zerolog.InfoLevel, 1<<20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := GlobalLogger()
+	l.Info().Msg("before close")
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w := closer.(*rotatingWriter)
+	if _, err := w.Write([]byte("after close\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write after Close: err = %v, want os.ErrClosed", err)
+	}
+	if w.file != nil {
+		t.Error("Write after Close reopened the log file")
+	}
+	data, err := os.ReadFile(logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "before close") {
+		t.Errorf("log file = %q, want the entry written before Close", data)
+	}
+	if strings.Contains(string(data), "after close") {
+		t.Errorf("log file = %q, has an entry written after Close", data)
+	}
+} // TestRotatingWriterWriteAfterClose
+
+func TestRotatingWriterCompressedBackups(t *testing.T) {
+	logName := filepath.Join(t.TempDir(), "app.log")
+	// a compressed backup left by an earlier run is replaced
+	if err := os.WriteFile(logName+".2.gz", []byte("stale"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := newRotatingWriter(logName, 0o644, int64(len("entry 1\n")), 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		if _, err := fmt.Fprintf(w, "entry %d\n", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// waits for the background compression to finish
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		logName:           "entry 4\n",
+		logName + ".1":    "entry 3\n",
+		logName + ".2.gz": "entry 2\n",
+		logName + ".3.gz": "entry 1\n",
+	} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if strings.HasSuffix(name, ".gz") {
+			if data, err = gunzip(data); err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+	for _, name := range []string{logName + ".2", logName + ".3", logName + ".4.gz"} {
+		if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s exists, err = %v", name, err)
+		}
+	}
+} // TestRotatingWriterCompressedBackups
+
+func TestRotatingWriterCompressionFails(t *testing.T) {
+	logName := filepath.Join(t.TempDir(), "app.log")
+	// the second backup cannot be compressed while a directory is in the
+	// way of its temporary file
+	blocker := logName + ".2.gz.tmp"
+	if err := os.Mkdir(blocker, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	w, err := newRotatingWriter(logName, 0o644, int64(len("entry 1\n")), 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	write := func(from, to int) {
+		for i := from; i <= to; i++ {
+			if _, err := fmt.Fprintf(w, "entry %d\n", i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// waits for the background compression to finish
+		w.pending.Wait()
+	}
+	errOutput, err := CaptureStderr(func() { write(1, 4) })
+	if err != nil || !strings.Contains(errOutput, "cannot compress log backup") {
+		t.Errorf("standard error = %q, %v, want the compression errors", errOutput, err)
+	}
+	// the backup that could not be compressed is shifted along uncompressed,
+	// and compressed once it can be
+	checkBackups(t, map[string]string{
+		logName:           "entry 4\n",
+		logName + ".1":    "entry 3\n",
+		logName + ".2":    "entry 2\n",
+		logName + ".3.gz": "entry 1\n",
+	}, logName+".2.gz", logName+".3")
+
+	if err := os.Remove(blocker); err != nil {
+		t.Fatal(err)
+	}
+	write(5, 5)
+	checkBackups(t, map[string]string{
+		logName:           "entry 5\n",
+		logName + ".1":    "entry 4\n",
+		logName + ".2.gz": "entry 3\n",
+		logName + ".3.gz": "entry 2\n",
+	}, logName+".2", logName+".3")
+} // TestRotatingWriterCompressionFails
+
+// checkBackups checks that each of the files named in `want` has the
+// wanted contents, once decompressed if its name ends with ".gz", and that
+// none of the files named in `missing` exists.
+func checkBackups(t *testing.T, want map[string]string, missing ...string) {
+	t.Helper()
+	for name, contents := range want {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if strings.HasSuffix(name, ".gz") {
+			if data, err = gunzip(data); err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+		}
+		if string(data) != contents {
+			t.Errorf("%s = %q, want %q", name, data, contents)
+		}
+	}
+	for _, name := range missing {
+		if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s exists, err = %v", name, err)
+		}
+	}
+} // checkBackups
+
+// gunzip returns the decompressed contents of the gzip data `data`.
+func gunzip(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+} // gunzip
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,365 @@
+// File: run.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Retry calls `fn` until it succeeds, up to `attempts` times in all,
+// sleeping for `delay` after each failure, and returns the result of the
+// first successful call:
+//
+//	```go
+//	conn, err := veil.Retry(5, time.Second, func() (net.Conn, error) {
+//	    return net.Dial("tcp", addr)
+//	})
+//
+// If every attempt fails then the error from the last attempt is returned,
+// along with the zero value. `fn` is always called at least once, even if
+// `attempts` is less than one.
+func Retry[T any](attempts int, delay time.Duration, fn func() (T, error)) (T, error) {
+	return retry(context.Background(), attempts, delay, 0, fn)
+} // Retry
+
+// RetryBackoff is like Retry, except that the delay doubles after each
+// failure, starting at `delay`, but never exceeds `maxDelay`. This backs off
+// from a struggling service rather than retrying at a steady rate.
+//
+// A `delay` larger than `maxDelay` is cut down to `maxDelay` from the first
+// sleep on. A `maxDelay` of zero or less means no growth: the delay stays at
+// `delay`, just as with Retry.
+func RetryBackoff[T any](
+	attempts int,
+	delay time.Duration,
+	maxDelay time.Duration,
+	fn func() (T, error),
+) (T, error) {
+	return retry(context.Background(), attempts, delay, maxDelay, fn)
+} // RetryBackoff
+
+// RetryContext is like Retry, except that it gives up once `ctx` is
+// cancelled or its deadline passes, both before each attempt and while
+// sleeping between attempts. An attempt that is already running is not
+// interrupted, so `fn` should also respect `ctx` if attempts are slow.
+//
+// When it gives up, the zero value is returned along with an error that
+// wraps both `ctx.Err()` and the error from the last attempt, if any.
+func RetryContext[T any](
+	ctx context.Context,
+	attempts int,
+	delay time.Duration,
+	fn func() (T, error),
+) (T, error) {
+	return retry(ctx, attempts, delay, 0, fn)
+} // RetryContext
+
+// RunWithTimeout runs function `f`, and returns its error, unless `f` does
+// not return within the duration `d`, in which case
+// context.DeadlineExceeded is returned instead. A panic in `f` is recovered
+// and returned as an error.
+//
+// Go cannot stop a running goroutine, so a timed-out `f` keeps running in
+// the background until it returns of its own accord, and its eventual
+// error is discarded. For timed-out work to really be cleaned up, `f`
+// must itself be cancellation-aware, e.g., by using a context with the
+// same deadline:
+//
+//	```go
+//	ctx, cancel := context.WithTimeout(context.Background(), d)
+//	defer cancel()
+//	err := veil.RunWithTimeout(d, func() error {
+//	    return fetch(ctx)
+//	})
+func RunWithTimeout(d time.Duration, f func() error) error {
+	done := make(chan error, 1) // buffered, so a timed-out `f` never blocks
+	go func() {
+		var err error
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("function panicked: %v", r)
+			}
+			done <- err
+		}()
+		err = f()
+	}()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return context.DeadlineExceeded
+	}
+} // RunWithTimeout
+
+// SafeRun runs function `f`, recovering any panic, and returning it as an
+// error; nil is returned if `f` does not panic. This is useful for running
+// callbacks, such as plugins, that must not be able to crash the program:
+//
+//	```go
+//	for _, plugin := range plugins {
+//	    if err := veil.SafeRun(plugin.Init); err != nil {
+//	        log.Error().Err(err).Str("plugin", plugin.Name).Msg("plugin failed")
+//	    }
+//	}
+//
+// The error's message includes the stack trace of the goroutine where `f`
+// panicked, as formatted by runtime/debug.Stack. If `f` panicked with an
+// error then the returned error wraps it, so errors.Is and errors.As still
+// work; `panic(nil)` is reported as a *runtime.PanicNilError.
+func SafeRun(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if panicErr, ok := r.(error); ok {
+				err = fmt.Errorf("function panicked: %w\n%s", panicErr, debug.Stack())
+			} else {
+				err = fmt.Errorf("function panicked: %v\n%s", r, debug.Stack())
+			}
+		}
+	}()
+	f()
+	return nil
+} // SafeRun
+
+// Debounce returns a function, `call`, that runs `fn` once `d` has passed
+// without `call` being called again, so a burst of calls runs `fn` just once,
+// after the burst. This suits, e.g., reloading a configuration file once
+// a flurry of file change notifications has died down:
+//
+//	```go
+//	reload, cancel := veil.Debounce(200*time.Millisecond, loadConfig)
+//	defer cancel()
+//	for range changes {
+//	    reload()
+//	}
+//
+// `fn` runs in a goroutine of its own. `cancel` stops any pending run of
+// `fn`, but does not wait for a run that has already started; calling
+// `call` afterwards starts a new wait. Both functions are safe to call
+// from multiple goroutines at once.
+func Debounce(d time.Duration, fn func()) (call func(), cancel func()) {
+	var mu sync.Mutex
+	var timer *time.Timer
+	call = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(d, fn)
+	}
+	cancel = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+			timer = nil
+		}
+	}
+	return call, cancel
+} // Debounce
+
+// Throttle returns a function that runs `fn` at most once in any period of
+// `d`: the first call runs `fn` straight away, and calls made within `d` of
+// that run are dropped, rather than delayed. This suits, e.g., limiting how
+// often a noisy condition is logged:
+//
+//	```go
+//	warnSlow := veil.Throttle(time.Minute, func() {
+//	    log.Warn().Msg("requests are slow")
+//	})
+//
+// `fn` runs in the goroutine of the call that runs it. The returned
+// function is safe to call from multiple goroutines at once.
+func Throttle(d time.Duration, fn func()) func() {
+	var mu sync.Mutex
+	var last time.Time
+	ran := false
+	return func() {
+		mu.Lock()
+		now := time.Now()
+		if ran && now.Sub(last) < d {
+			mu.Unlock()
+			return
+		}
+		ran, last = true, now
+		mu.Unlock()
+		fn()
+	}
+} // Throttle
+
+// RunWithEnv runs function `f` with the environment variables in `env` set,
+// or unset if their values are empty, and then restores the whole
+// environment, undoing any changes made by `f` too, even if `f` panics. This
+// keeps tests of code that reads or sets environment variables hermetic:
+//
+//	```go
+//	veil.RunWithEnv(map[string]string{"LOG_LEVEL": "debug", "HOME": ""}, func() {
+//	    out, err := veil.CaptureOutput(runCLI)
+//	    ...
+//	})
+//
+// Since the environment is shared by the whole process, calls to RunWithEnv
+// run one at a time, so `f` must not itself call RunWithEnv. Other code that
+// changes the environment while `f` runs, however, is not held up, and may
+// see the changes, or have its own changes undone. If `f` panics then the
+// panic carries on once the environment has been restored.
+func RunWithEnv(env map[string]string, f func()) {
+	envMu.Lock()
+	defer envMu.Unlock()
+	defer restoreEnv(os.Environ())
+	for name, val := range env {
+		if val == "" {
+			IgnoreError(os.Unsetenv(name))
+		} else {
+			IgnoreError(os.Setenv(name, val))
+		}
+	}
+	f()
+} // RunWithEnv
+
+// envMu serializes the changes to the environment made by RunWithEnv.
+var envMu sync.Mutex
+
+// restoreEnv makes the environment exactly `environ`,
+// as returned by os.Environ.
+func restoreEnv(environ []string) {
+	os.Clearenv()
+	for _, kv := range environ {
+		if kv == "" {
+			continue
+		}
+		// on Windows, the names of some variables begin with "="
+		if name, val, ok := strings.Cut(kv[1:], "="); ok {
+			IgnoreError(os.Setenv(kv[:1]+name, val))
+		}
+	}
+} // restoreEnv
+
+// RunInDir runs function `f` with the current working directory changed to
+// `dir`, and then changes it back, even if `f` panics. This suits tests of
+// code that uses the current working directory, e.g., through FilePathInCwd:
+//
+//	```go
+//	err := veil.RunInDir(t.TempDir(), func() {
+//	    path, err := veil.FilePathInCwd("app.log")
+//	    ...
+//	})
+//
+// The current working directory is shared by the whole process, so, like
+// the capture functions, calls to RunInDir run one at a time, and also one
+// at a time with the capture functions; `f` must therefore not call a
+// capture function, nor RunInDir, which would deadlock. Other code that
+// changes the current working directory while `f` runs is not held up.
+//
+// An error is returned if the current working directory cannot be changed
+// to `dir`, in which case `f` is not run, or cannot be changed back. If `f`
+// panics then the panic is recovered and returned as an error, as by SafeRun.
+func RunInDir(dir string, f func()) (err error) {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	previous, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err = os.Chdir(dir); err != nil {
+		return err
+	}
+	defer func() {
+		if chdirErr := os.Chdir(previous); chdirErr != nil {
+			err = errors.Join(err, fmt.Errorf(
+				"cannot restore the working directory: %w", chdirErr))
+		}
+	}()
+	return SafeRun(f)
+} // RunInDir
+
+// retry calls `fn` up to `attempts` times, as Retry does, giving up if
+// `ctx` is done. If `maxDelay` is positive then the delay is at most
+// `maxDelay`, and doubles after each failure up to that; otherwise the
+// delay stays the same.
+func retry[T any](
+	ctx context.Context,
+	attempts int,
+	delay time.Duration,
+	maxDelay time.Duration,
+	fn func() (T, error),
+) (T, error) {
+	var zero T
+	var lastErr error
+	if maxDelay > 0 {
+		delay = min(delay, maxDelay)
+	}
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return zero, givenUp(err, lastErr)
+		}
+		result, err := fn()
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+		if attempt >= attempts {
+			return zero, lastErr
+		}
+		if err := retrySleep(ctx, delay); err != nil {
+			return zero, givenUp(err, lastErr)
+		}
+		if maxDelay > 0 {
+			delay = min(2*delay, maxDelay)
+		}
+	}
+} // retry
+
+// retrySleep sleeps for the duration `d` between the attempts of retry,
+// returning ctx.Err() early if `ctx` is done first. Tests replace it to
+// record the delays without waiting for them.
+var retrySleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+} // retrySleep
+
+// givenUp returns the error for retries that were given up because of the
+// context error `ctxErr`, wrapping the error from the last attempt, if any.
+func givenUp(ctxErr, lastErr error) error {
+	if lastErr == nil {
+		return ctxErr
+	}
+	return fmt.Errorf("%w, last error: %w", ctxErr, lastErr)
+} // givenUp
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
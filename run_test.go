@@ -0,0 +1,342 @@
+// File: run_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingUntil returns a function for the retry helpers that fails until
+// its `n`th call, recording the time of each call in `calls`.
+func failingUntil(n int, calls *[]time.Time) func() (int, error) {
+	return func() (int, error) {
+		*calls = append(*calls, time.Now())
+		if len(*calls) < n {
+			return 0, fmt.Errorf("attempt %d failed", len(*calls))
+		}
+		return len(*calls), nil
+	}
+} // failingUntil
+
+func TestRetry(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	var calls []time.Time
+	start := time.Now()
+	result, err := Retry(5, delay, failingUntil(3, &calls))
+	if err != nil || result != 3 || len(calls) != 3 {
+		t.Errorf("Retry() = %d, %v after %d calls, want success on the 3rd call",
+			result, err, len(calls))
+	}
+	if elapsed := time.Since(start); elapsed < 2*delay || elapsed > 2*delay+time.Second {
+		t.Errorf("Retry() took %v, want about %v", elapsed, 2*delay)
+	}
+
+	calls = nil
+	result, err = Retry(3, time.Millisecond, failingUntil(10, &calls))
+	if err == nil || err.Error() != "attempt 3 failed" || result != 0 || len(calls) != 3 {
+		t.Errorf("Retry() = %d, %v after %d calls, want the last error after 3 calls",
+			result, err, len(calls))
+	}
+
+	calls = nil
+	if _, err = Retry(0, time.Hour, failingUntil(10, &calls)); err == nil || len(calls) != 1 {
+		t.Errorf("Retry(0) err = %v after %d calls, want one failed call", err, len(calls))
+	}
+} // TestRetry
+
+func TestRetryBackoff(t *testing.T) {
+	const ms = time.Millisecond
+	for _, test := range []struct {
+		delay, maxDelay time.Duration
+		want            []time.Duration
+	}{
+		// the delay doubles after each failure, up to the maximum
+		{20 * ms, 50 * ms, []time.Duration{20 * ms, 40 * ms, 50 * ms, 50 * ms}},
+		// a delay over the maximum is cut down from the start
+		{80 * ms, 50 * ms, []time.Duration{50 * ms, 50 * ms, 50 * ms, 50 * ms}},
+		// no maximum means no growth
+		{20 * ms, 0, []time.Duration{20 * ms, 20 * ms, 20 * ms, 20 * ms}},
+	} {
+		sleeps := recordRetrySleeps(t)
+		var calls []time.Time
+		_, err := RetryBackoff(5, test.delay, test.maxDelay, failingUntil(5, &calls))
+		if err != nil || !slices.Equal(*sleeps, test.want) {
+			t.Errorf("RetryBackoff(5, %v, %v) slept for %v, err = %v, want %v",
+				test.delay, test.maxDelay, *sleeps, err, test.want)
+		}
+	}
+} // TestRetryBackoff
+
+// recordRetrySleeps makes retry record the delays it sleeps for, without
+// sleeping, until the end of the test.
+func recordRetrySleeps(t *testing.T) *[]time.Duration {
+	previous := retrySleep
+	t.Cleanup(func() { retrySleep = previous })
+	sleeps := new([]time.Duration)
+	retrySleep = func(_ context.Context, d time.Duration) error {
+		*sleeps = append(*sleeps, d)
+		return nil
+	}
+	return sleeps
+} // recordRetrySleeps
+
+func TestRetryContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var calls []time.Time
+	start := time.Now()
+	_, err := RetryContext(ctx, 100, 20*time.Millisecond, failingUntil(1000, &calls))
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("err = %v, want the deadline and the last error", err)
+	}
+	if n := len(calls); n < 2 || n > 4 {
+		t.Errorf("RetryContext() made %d calls, want about 3 before the deadline", n)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("RetryContext() took %v, want it to give up at the deadline", elapsed)
+	}
+
+	calls = nil
+	_, err = RetryContext(ctx, 3, time.Millisecond, failingUntil(1, &calls))
+	if !errors.Is(err, context.DeadlineExceeded) || len(calls) != 0 {
+		t.Errorf("err = %v after %d calls, want no calls once the context is done",
+			err, len(calls))
+	}
+} // TestRetryContext
+
+func TestRunWithTimeout(t *testing.T) {
+	failed := errors.New("failed")
+	if err := RunWithTimeout(time.Second, func() error { return failed }); !errors.Is(err, failed) {
+		t.Errorf("RunWithTimeout() = %v, want the error from f", err)
+	}
+	if err := RunWithTimeout(time.Second, func() error { return nil }); err != nil {
+		t.Errorf("RunWithTimeout() = %v, want nil", err)
+	}
+	finished := make(chan struct{})
+	start := time.Now()
+	err := RunWithTimeout(20*time.Millisecond, func() error {
+		defer close(finished)
+		time.Sleep(100 * time.Millisecond)
+		return failed
+	})
+	if elapsed := time.Since(start); !errors.Is(err, context.DeadlineExceeded) ||
+		elapsed >= 100*time.Millisecond {
+		t.Errorf("RunWithTimeout() = %v after %v, want a timeout after 20ms", err, elapsed)
+	}
+	// the timed-out function keeps running in the background
+	select {
+	case <-finished:
+		t.Error("the timed-out function had already finished")
+	default:
+	}
+	<-finished
+	if err := RunWithTimeout(time.Second, func() error { panic("oops") }); err == nil ||
+		!strings.Contains(err.Error(), "oops") {
+		t.Errorf("RunWithTimeout() = %v, want the panic", err)
+	}
+} // TestRunWithTimeout
+
+func TestSafeRun(t *testing.T) {
+	if err := SafeRun(func() {}); err != nil {
+		t.Errorf("SafeRun() = %v, want nil", err)
+	}
+
+	err := SafeRun(func() { panic(io.ErrUnexpectedEOF) })
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("SafeRun() = %v, want an error wrapping the panic", err)
+	}
+
+	err = SafeRun(func() { panic("plugin broke") })
+	if err == nil || !strings.Contains(err.Error(), "function panicked: plugin broke") {
+		t.Errorf("SafeRun() = %v, want the panic message", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "TestSafeRun") {
+		t.Errorf("SafeRun() = %v, want the stack trace", err)
+	}
+
+	err = SafeRun(func() { panic(nil) })
+	var nilErr *runtime.PanicNilError
+	if !errors.As(err, &nilErr) {
+		t.Errorf("SafeRun() = %v, want a *runtime.PanicNilError", err)
+	}
+} // TestSafeRun
+
+func TestDebounce(t *testing.T) {
+	var runs atomic.Int32
+	call, cancel := Debounce(100*time.Millisecond, func() { runs.Add(1) })
+	defer cancel()
+	for i := 0; i < 10; i++ {
+		call()
+		time.Sleep(time.Millisecond)
+	}
+	if n := runs.Load(); n != 0 {
+		t.Errorf("fn ran %d times during the burst, want none", n)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if n := runs.Load(); n != 1 {
+		t.Errorf("fn ran %d times after the burst, want once", n)
+	}
+
+	call()
+	cancel()
+	time.Sleep(300 * time.Millisecond)
+	if n := runs.Load(); n != 1 {
+		t.Errorf("fn ran %d times in all, want the cancelled run dropped", n)
+	}
+} // TestDebounce
+
+func TestThrottle(t *testing.T) {
+	var runs atomic.Int32
+	throttled := Throttle(time.Hour, func() { runs.Add(1) })
+	throttled()
+	if n := runs.Load(); n != 1 {
+		t.Fatalf("fn ran %d times after the first call, want once straight away", n)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				throttled()
+			}
+		}()
+	}
+	wg.Wait()
+	if n := runs.Load(); n != 1 {
+		t.Errorf("fn ran %d times within the window, want once", n)
+	}
+
+	runs.Store(0)
+	throttled = Throttle(20*time.Millisecond, func() { runs.Add(1) })
+	throttled()
+	time.Sleep(50 * time.Millisecond)
+	throttled()
+	throttled()
+	if n := runs.Load(); n != 2 {
+		t.Errorf("fn ran %d times, want once in each of two windows", n)
+	}
+} // TestThrottle
+
+func TestRunWithEnv(t *testing.T) {
+	t.Setenv("VEIL_TEST_KEEP", "kept")
+	t.Setenv("VEIL_TEST_GONE", "set")
+	os.Unsetenv("VEIL_TEST_INSIDE")
+	RunWithEnv(map[string]string{"VEIL_TEST_OVERRIDE": "on", "VEIL_TEST_GONE": ""}, func() {
+		if got := os.Getenv("VEIL_TEST_OVERRIDE"); got != "on" {
+			t.Errorf("VEIL_TEST_OVERRIDE = %q inside, want \"on\"", got)
+		}
+		if _, ok := os.LookupEnv("VEIL_TEST_GONE"); ok {
+			t.Error("VEIL_TEST_GONE is set inside, want it unset")
+		}
+		os.Setenv("VEIL_TEST_INSIDE", "leaked")
+		os.Unsetenv("VEIL_TEST_KEEP")
+	})
+	assertEnvRestored(t)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recovered %v, want the panic passed on", r)
+			}
+		}()
+		RunWithEnv(nil, func() {
+			os.Setenv("VEIL_TEST_INSIDE", "leaked")
+			panic("boom")
+		})
+	}()
+	assertEnvRestored(t)
+} // TestRunWithEnv
+
+// assertEnvRestored fails the test `t` unless the environment is as
+// TestRunWithEnv set it up, before calling RunWithEnv.
+func assertEnvRestored(t *testing.T) {
+	t.Helper()
+	for name, want := range map[string]string{
+		"VEIL_TEST_KEEP": "kept", "VEIL_TEST_GONE": "set",
+	} {
+		if got := os.Getenv(name); got != want {
+			t.Errorf("%s = %q afterwards, want %q", name, got, want)
+		}
+	}
+	for _, name := range []string{"VEIL_TEST_OVERRIDE", "VEIL_TEST_INSIDE"} {
+		if val, ok := os.LookupEnv(name); ok {
+			t.Errorf("%s = %q afterwards, want it unset", name, val)
+		}
+	}
+} // assertEnvRestored
+
+func TestRunInDir(t *testing.T) {
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inside string
+	err = RunInDir(dir, func() {
+		inside, _ = FilePathInCwd("app.log")
+	})
+	if err != nil || inside != filepath.Join(dir, "app.log") {
+		t.Errorf("RunInDir() = %v, with FilePathInCwd() = %q inside, want %q",
+			err, inside, filepath.Join(dir, "app.log"))
+	}
+	assertCwd(t, previous)
+
+	err = RunInDir(dir, func() { panic("boom") })
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("RunInDir() = %v, want the panic as an error", err)
+	}
+	assertCwd(t, previous)
+
+	if err := RunInDir(filepath.Join(dir, "missing"), func() {
+		t.Error("f ran without the directory")
+	}); err == nil {
+		t.Error("RunInDir() with a missing directory succeeded")
+	}
+	assertCwd(t, previous)
+} // TestRunInDir
+
+// assertCwd fails the test `t` unless the current working directory is `want`.
+func assertCwd(t *testing.T, want string) {
+	t.Helper()
+	if cwd, err := os.Getwd(); err != nil || cwd != want {
+		t.Errorf("working directory = %q, %v, want %q", cwd, err, want)
+	}
+} // assertCwd
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
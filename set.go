@@ -0,0 +1,132 @@
+// File: set.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import "cmp"
+
+// Set is a set of values of type T, backed by a map. Sets are made by
+// NewSet, or by make, just as maps are, and, like maps, the zero value of
+// a Set is nil, which can be read from, but not added to:
+//
+//	```go
+//	seen := veil.NewSet[string]()
+//	for _, name := range names {
+//	    if seen.Contains(name) {
+//	        fmt.Println("duplicate:", name)
+//	    }
+//	    seen.Add(name)
+//	}
+//
+// A Set can also be ranged over, as a map, to visit its values; as with
+// maps, it is not safe to change a Set while another goroutine uses it.
+type Set[T comparable] map[T]struct{}
+
+// NewSet returns a new set holding `vals`.
+func NewSet[T comparable](vals ...T) Set[T] {
+	s := make(Set[T], len(vals))
+	s.Add(vals...)
+	return s
+} // NewSet
+
+// Add adds `vals` to the set. Values already in the set are left alone.
+func (s Set[T]) Add(vals ...T) {
+	for _, val := range vals {
+		s[val] = struct{}{}
+	}
+} // Add
+
+// Remove removes `vals` from the set. Values that are not in the set are
+// ignored.
+func (s Set[T]) Remove(vals ...T) {
+	for _, val := range vals {
+		delete(s, val)
+	}
+} // Remove
+
+// Contains reports whether `val` is in the set.
+func (s Set[T]) Contains(val T) bool {
+	_, ok := s[val]
+	return ok
+} // Contains
+
+// Len returns the number of values in the set.
+func (s Set[T]) Len() int {
+	return len(s)
+} // Len
+
+// Slice returns the values in the set as a new slice. The order of the
+// values is unspecified, as Go's map iteration order is; use SortedSlice
+// when the order matters, e.g., in tests.
+func (s Set[T]) Slice() []T {
+	return Keys(s)
+} // Slice
+
+// Union returns a new set holding the values that are in
+// either the set or `other`, or in both of them.
+func (s Set[T]) Union(other Set[T]) Set[T] {
+	union := make(Set[T], max(len(s), len(other)))
+	for val := range s {
+		union[val] = struct{}{}
+	}
+	for val := range other {
+		union[val] = struct{}{}
+	}
+	return union
+} // Union
+
+// Intersect returns a new set holding the values that are
+// in both the set and `other`.
+func (s Set[T]) Intersect(other Set[T]) Set[T] {
+	small, large := s, other
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	intersection := make(Set[T])
+	for val := range small {
+		if large.Contains(val) {
+			intersection[val] = struct{}{}
+		}
+	}
+	return intersection
+} // Intersect
+
+// Difference returns a new set holding the values that
+// are in the set, but not in `other`.
+func (s Set[T]) Difference(other Set[T]) Set[T] {
+	difference := make(Set[T])
+	for val := range s {
+		if !other.Contains(val) {
+			difference[val] = struct{}{}
+		}
+	}
+	return difference
+} // Difference
+
+// SortedSlice returns the values in the set `s` as a new slice, like
+// Set.Slice does, but sorted into ascending order. It is a function, rather
+// than a method, as only sets of ordered types can be sorted.
+func SortedSlice[T cmp.Ordered](s Set[T]) []T {
+	return SortedKeys(s)
+} // SortedSlice
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
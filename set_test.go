@@ -0,0 +1,82 @@
+// File: set_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	s := NewSet("b", "a", "b")
+	if s.Len() != 2 || !s.Contains("a") || !s.Contains("b") || s.Contains("c") {
+		t.Errorf("NewSet() = %v, want {a, b}", s)
+	}
+	s.Add("c", "a")
+	s.Remove("b", "missing")
+	if got := SortedSlice(s); !slices.Equal(got, []string{"a", "c"}) {
+		t.Errorf("set = %q, want [a c]", got)
+	}
+	got := s.Slice()
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"a", "c"}) {
+		t.Errorf("Slice() = %q, want a and c in any order", got)
+	}
+
+	var empty Set[string]
+	if empty.Len() != 0 || empty.Contains("a") || len(empty.Slice()) != 0 {
+		t.Errorf("nil set = %v, want it empty", empty)
+	}
+} // TestSet
+
+func TestSetAlgebra(t *testing.T) {
+	a, b := NewSet(1, 2, 3, 4), NewSet(3, 4, 5)
+	var none Set[int]
+	tests := []struct {
+		name string
+		got  Set[int]
+		want []int
+	}{
+		{name: "union", got: a.Union(b), want: []int{1, 2, 3, 4, 5}},
+		{name: "union with nil", got: a.Union(none), want: []int{1, 2, 3, 4}},
+		{name: "intersect", got: a.Intersect(b), want: []int{3, 4}},
+		{name: "intersect reversed", got: b.Intersect(a), want: []int{3, 4}},
+		{name: "intersect with nil", got: a.Intersect(none), want: nil},
+		{name: "difference", got: a.Difference(b), want: []int{1, 2}},
+		{name: "difference reversed", got: b.Difference(a), want: []int{5}},
+		{name: "difference with nil", got: a.Difference(none), want: []int{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		if got := SortedSlice(tt.got); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	// the results are new sets, leaving the originals alone
+	a.Union(b).Add(9)
+	if !slices.Equal(SortedSlice(a), []int{1, 2, 3, 4}) ||
+		!slices.Equal(SortedSlice(b), []int{3, 4, 5}) {
+		t.Errorf("sets changed to %v and %v", a, b)
+	}
+} // TestSetAlgebra
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,129 @@
+// File: signal.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+)
+
+// InstallSignalFlush makes the program close `closer`, e.g., the io.Closer
+// of a buffered or rotating log, when it receives an interrupt (SIGINT) or
+// termination (SIGTERM) signal, so that the last log entries are not lost.
+// The signal is then raised again, so the program still terminates as it
+// would have done without this function:
+//
+//	```go
+//	closer, err := veil.SetGlobalZerologBuffered("app.log", level, 1000)
+//	if err != nil {
+//	    return err
+//	}
+//	defer closer.Close()
+//	stop := veil.InstallSignalFlush(closer)
+//	defer stop()
+//
+// The returned `stop` function stops listening for the signals, and may be
+// called more than once. Only the first signal is handled; if some other
+// code is also listening for it then the raised signal goes to that code
+// instead, and may not terminate the program. If the signal cannot be
+// raised again, e.g., on Windows, then the program exits by calling
+// ExitFunc with the exit code that a shell would report, 128 plus the
+// signal number.
+//
+// Installing again for the same `closer`, before its signal has been handled
+// or `stop` has been called, does nothing more, and returns the same `stop`
+// function, so that `closer` is only ever closed once. Closers whose type is
+// not comparable, such as slices, cannot be told apart, so each install for
+// one of those listens for the signals afresh.
+//
+// An error from closing `closer` is reported on standard error.
+func InstallSignalFlush(closer io.Closer) (stop func()) {
+	known := closer != nil && reflect.TypeOf(closer).Comparable()
+	signalFlushMu.Lock()
+	defer signalFlushMu.Unlock()
+	if known {
+		if stop, ok := signalFlushes[closer]; ok {
+			return stop
+		}
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(stopped)
+			if known {
+				signalFlushMu.Lock()
+				delete(signalFlushes, closer)
+				signalFlushMu.Unlock()
+			}
+		})
+	}
+	if known {
+		signalFlushes[closer] = stop
+	}
+	go func() {
+		select {
+		case sig := <-sigs:
+			stop()
+			flushAndRaise(closer, sig)
+		case <-stopped:
+		}
+	}()
+	return stop
+} // InstallSignalFlush
+
+// signalFlushMu guards `signalFlushes`.
+var signalFlushMu sync.Mutex
+
+// signalFlushes holds the `stop` function of each closer that
+// InstallSignalFlush is listening for the signals for.
+var signalFlushes = make(map[io.Closer]func())
+
+// flushAndRaise closes `closer`, and then raises `sig` again, which
+// must no longer be being listened for by this package.
+func flushAndRaise(closer io.Closer, sig os.Signal) {
+	if err := closer.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "veil: cannot flush the log on %v: %v\n", sig, err)
+	}
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(sig)
+	}
+	if err != nil {
+		code := 1
+		if num, ok := sig.(syscall.Signal); ok {
+			code = 128 + int(num)
+		}
+		ExitFunc(code)
+	}
+} // flushAndRaise
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,120 @@
+//go:build unix
+
+// File: signal_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// closeNotifier is an io.Closer that reports each time it is closed.
+type closeNotifier chan struct{}
+
+func (c closeNotifier) Close() error {
+	c <- struct{}{}
+	return nil
+}
+
+func TestInstallSignalFlush(t *testing.T) {
+	// while the test listens for SIGTERM too, the raised signal goes
+	// to the test, rather than terminating it
+	raised := make(chan os.Signal, 2)
+	signal.Notify(raised, syscall.SIGTERM)
+	defer signal.Stop(raised)
+
+	closed := make(closeNotifier, 1)
+	stop := InstallSignalFlush(closed)
+	defer stop()
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closer not closed on SIGTERM")
+	}
+	// the signal sent, and then the one raised again
+	for i := 0; i < 2; i++ {
+		select {
+		case <-raised:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d signals, want the signal raised again", i)
+		}
+	}
+
+	stop = InstallSignalFlush(closed)
+	stop()
+	stop()
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	<-raised
+	select {
+	case <-closed:
+		t.Error("closer closed after stop")
+	case <-time.After(100 * time.Millisecond):
+	}
+} // TestInstallSignalFlush
+
+func TestInstallSignalFlushTwice(t *testing.T) {
+	raised := make(chan os.Signal, 3)
+	signal.Notify(raised, syscall.SIGTERM)
+	defer signal.Stop(raised)
+
+	closed := make(closeNotifier, 2)
+	stop := InstallSignalFlush(closed)
+	defer stop()
+	// installing again for the same closer does not listen twice
+	stopAgain := InstallSignalFlush(closed)
+	defer stopAgain()
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closer not closed on SIGTERM")
+	}
+	// the signal sent, and then the one raised again
+	for i := 0; i < 2; i++ {
+		select {
+		case <-raised:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d signals, want the signal raised again", i)
+		}
+	}
+	select {
+	case <-closed:
+		t.Error("closer closed twice")
+	case <-raised:
+		t.Error("signal raised twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+} // TestInstallSignalFlushTwice
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,225 @@
+// File: slices.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"fmt"
+)
+
+// MapSlice returns a new slice holding the result of calling `fn` on each
+// element of `in`, in order:
+//
+//	```go
+//	lengths := veil.MapSlice(names, func(name string) int {
+//	    return len(name)
+//	})
+//
+// A nil `in` gives a nil result, while an empty `in` that is not nil gives
+// an empty result that is not nil.
+func MapSlice[T, U any](in []T, fn func(T) U) []U {
+	if in == nil {
+		return nil
+	}
+	out := make([]U, len(in))
+	for i, v := range in {
+		out[i] = fn(v)
+	}
+	return out
+} // MapSlice
+
+// FilterSlice returns a new slice holding, in order, the elements of `in`
+// for which `keep` returns true. `in` itself is not changed.
+//
+// A nil `in` gives a nil result; otherwise the result is not nil, even if
+// no elements are kept.
+func FilterSlice[T any](in []T, keep func(T) bool) []T {
+	if in == nil {
+		return nil
+	}
+	out := []T{}
+	for _, v := range in {
+		if keep(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+} // FilterSlice
+
+// Reduce folds the elements of `in` into an accumulator, starting with
+// `initial`, and returns the result. The elements are folded in from left
+// to right, so that fn(fn(fn(initial, in[0]), in[1]), in[2]) is returned
+// for a slice of three elements:
+//
+//	```go
+//	total := veil.Reduce(prices, 0.0, func(sum, price float64) float64 {
+//	    return sum + price
+//	})
+//
+// `initial` is returned unchanged if `in` is empty.
+func Reduce[T, A any](in []T, initial A, fn func(A, T) A) A {
+	acc := initial
+	for _, v := range in {
+		acc = fn(acc, v)
+	}
+	return acc
+} // Reduce
+
+// GroupBy partitions the elements of `in` into groups, by the key that
+// `key` returns for each element, and returns a map from each key to its
+// group. Within each group, the elements keep their order in `in`:
+//
+//	```go
+//	byDept := veil.GroupBy(staff, func(e Employee) string {
+//	    return e.Dept
+//	})
+//
+// A nil `in` gives a nil map, which can still be read from, while an empty
+// `in` that is not nil gives an empty map that is not nil.
+func GroupBy[T any, K comparable](in []T, key func(T) K) map[K][]T {
+	if in == nil {
+		return nil
+	}
+	groups := make(map[K][]T)
+	for _, v := range in {
+		k := key(v)
+		groups[k] = append(groups[k], v)
+	}
+	return groups
+} // GroupBy
+
+// Chunk splits `in` into consecutive chunks of `size` elements, except for
+// the last chunk, which holds whatever elements remain. This suits batching
+// work, such as database writes, into groups of a limited size:
+//
+//	```go
+//	for _, batch := range veil.Chunk(rows, 100) {
+//	    insert(batch)
+//	}
+//
+// The chunks share `in`'s memory, but each is capped at its own length, so
+// appending to one chunk never overwrites the next. An empty, or nil, `in`
+// gives no chunks, i.e., a nil result.
+//
+// Chunk panics if `size` is not positive, as that is a programming error,
+// like the errors that Must panics with.
+func Chunk[T any](in []T, size int) [][]T {
+	if size <= 0 {
+		panic(fmt.Sprintf("veil.Chunk: invalid chunk size %d", size))
+	}
+	var chunks [][]T
+	for start := 0; start < len(in); start += size {
+		end := min(start+size, len(in))
+		chunks = append(chunks, in[start:end:end])
+	}
+	return chunks
+} // Chunk
+
+// Unique returns a new slice holding the elements of `in` with any
+// duplicates removed, keeping the first occurrence of each element, and
+// so the order in which the elements first occur. `in` is not changed.
+//
+// A nil `in` gives a nil result.
+func Unique[T comparable](in []T) []T {
+	return UniqueFunc(in, func(v T) T {
+		return v
+	})
+} // Unique
+
+// UniqueFunc is like Unique, except that two elements count as duplicates
+// if `key` returns the same key for both of them. This allows elements that
+// are not comparable, such as structs holding slices, to be deduplicated
+// by a comparable field, say.
+func UniqueFunc[T any, K comparable](in []T, key func(T) K) []T {
+	if in == nil {
+		return nil
+	}
+	seen := make(map[K]struct{}, len(in))
+	out := []T{}
+	for _, v := range in {
+		k := key(v)
+		if _, ok := seen[k]; !ok {
+			seen[k] = struct{}{}
+			out = append(out, v)
+		}
+	}
+	return out
+} // UniqueFunc
+
+// First returns the first element of `s`, and true, or the zero value of
+// its type, and false, if `s` is empty. Unlike `s[0]`, it never panics:
+//
+//	```go
+//	if arg, ok := veil.First(os.Args[1:]); ok {
+//	    fmt.Println("first argument:", arg)
+//	}
+func First[T any](s []T) (T, bool) {
+	if len(s) == 0 {
+		var zero T
+		return zero, false
+	}
+	return s[0], true
+} // First
+
+// Last returns the last element of `s`, and true, or the zero value of
+// its type, and false, if `s` is empty. Unlike `s[len(s)-1]`, it never panics.
+func Last[T any](s []T) (T, bool) {
+	if len(s) == 0 {
+		var zero T
+		return zero, false
+	}
+	return s[len(s)-1], true
+} // Last
+
+// Contains reports whether `v` is one of the elements of `s`.
+func Contains[T comparable](s []T, v T) bool {
+	return IndexOf(s, v) >= 0
+} // Contains
+
+// ContainsFunc reports whether `match` returns true for any of the elements
+// of `s`. It works for elements that are not comparable, such as slices:
+//
+//	```go
+//	hasEmpty := veil.ContainsFunc(rows, func(row []string) bool {
+//	    return len(row) == 0
+//	})
+func ContainsFunc[T any](s []T, match func(T) bool) bool {
+	for _, e := range s {
+		if match(e) {
+			return true
+		}
+	}
+	return false
+} // ContainsFunc
+
+// IndexOf returns the index of the first element of `s`
+// that equals `v`, or -1 if there is no such element.
+func IndexOf[T comparable](s []T, v T) int {
+	for i, e := range s {
+		if e == v {
+			return i
+		}
+	}
+	return -1
+} // IndexOf
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
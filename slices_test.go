@@ -0,0 +1,257 @@
+// File: slices_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestMapSlice(t *testing.T) {
+	got := MapSlice([]int{1, 22, 333}, strconv.Itoa)
+	if !slices.Equal(got, []string{"1", "22", "333"}) {
+		t.Errorf("MapSlice() = %q, want [1 22 333]", got)
+	}
+	if got := MapSlice(nil, strconv.Itoa); got != nil {
+		t.Errorf("MapSlice(nil) = %#v, want nil", got)
+	}
+	if got := MapSlice([]int{}, strconv.Itoa); got == nil || len(got) != 0 {
+		t.Errorf("MapSlice([]int{}) = %#v, want an empty slice", got)
+	}
+} // TestMapSlice
+
+func TestFilterSlice(t *testing.T) {
+	in := []int{1, 2, 3, 4, 5, 6}
+	even := func(n int) bool { return n%2 == 0 }
+	if got := FilterSlice(in, even); !slices.Equal(got, []int{2, 4, 6}) {
+		t.Errorf("FilterSlice() = %v, want [2 4 6]", got)
+	}
+	if !slices.Equal(in, []int{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("FilterSlice() changed its input to %v", in)
+	}
+	if got := FilterSlice(nil, even); got != nil {
+		t.Errorf("FilterSlice(nil) = %#v, want nil", got)
+	}
+	if got := FilterSlice([]int{1, 3}, even); got == nil || len(got) != 0 {
+		t.Errorf("FilterSlice() = %#v, want an empty slice", got)
+	}
+} // TestFilterSlice
+
+func TestReduce(t *testing.T) {
+	sum := func(acc, n int) int { return acc + n }
+	if got := Reduce([]int{1, 2, 3, 4}, 0, sum); got != 10 {
+		t.Errorf("Reduce() = %d, want 10", got)
+	}
+	concat := func(acc, s string) string { return acc + s }
+	if got := Reduce([]string{"a", "b", "c"}, ">", concat); got != ">abc" {
+		t.Errorf("Reduce() = %q, want \">abc\", folded from the left", got)
+	}
+	if got := Reduce(nil, 7, sum); got != 7 {
+		t.Errorf("Reduce(nil) = %d, want the initial 7", got)
+	}
+	if got := Reduce([]string{}, "initial", concat); got != "initial" {
+		t.Errorf("Reduce([]string{}) = %q, want \"initial\"", got)
+	}
+} // TestReduce
+
+func TestGroupBy(t *testing.T) {
+	type employee struct {
+		name, dept string
+	}
+	staff := []employee{
+		{"ann", "eng"}, {"bob", "ops"}, {"cat", "eng"}, {"dan", "eng"}, {"eve", "ops"},
+	}
+	byDept := func(e employee) string { return e.dept }
+	tests := []struct {
+		name string
+		in   []employee
+		want map[string][]employee
+	}{
+		{name: "nil", in: nil, want: nil},
+		{name: "empty", in: []employee{}, want: map[string][]employee{}},
+		{name: "one group", in: staff[:1], want: map[string][]employee{"eng": staff[:1]}},
+		{name: "groups in order", in: staff, want: map[string][]employee{
+			"eng": {staff[0], staff[2], staff[3]},
+			"ops": {staff[1], staff[4]},
+		}},
+	}
+	for _, tt := range tests {
+		got := GroupBy(tt.in, byDept)
+		if (got == nil) != (tt.want == nil) || len(got) != len(tt.want) {
+			t.Errorf("%s: GroupBy() = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for dept, group := range tt.want {
+			if !slices.Equal(got[dept], group) {
+				t.Errorf("%s: GroupBy()[%q] = %v, want %v", tt.name, dept, got[dept], group)
+			}
+		}
+	}
+} // TestGroupBy
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []int
+		size int
+		want [][]int
+	}{
+		{name: "exact multiple", in: []int{1, 2, 3, 4}, size: 2,
+			want: [][]int{{1, 2}, {3, 4}}},
+		{name: "remainder", in: []int{1, 2, 3, 4, 5}, size: 2,
+			want: [][]int{{1, 2}, {3, 4}, {5}}},
+		{name: "larger size", in: []int{1, 2}, size: 5, want: [][]int{{1, 2}}},
+		{name: "empty", in: []int{}, size: 3, want: nil},
+		{name: "nil", in: nil, size: 3, want: nil},
+	}
+	for _, tt := range tests {
+		got := Chunk(tt.in, tt.size)
+		if !slices.EqualFunc(got, tt.want, slices.Equal[[]int]) {
+			t.Errorf("%s: Chunk() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	// appending to a chunk does not overwrite the next one
+	in := []int{1, 2, 3, 4}
+	chunks := Chunk(in, 2)
+	_ = append(chunks[0], 99)
+	if !slices.Equal(chunks[1], []int{3, 4}) {
+		t.Errorf("appending to a chunk changed the next to %v", chunks[1])
+	}
+	for _, size := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Chunk(%d) did not panic", size)
+				}
+			}()
+			Chunk(in, size)
+		}()
+	}
+} // TestChunk
+
+func TestUnique(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{name: "nil", in: nil, want: nil},
+		{name: "all duplicates", in: []string{"a", "a", "a"}, want: []string{"a"}},
+		{name: "no duplicates", in: []string{"c", "a", "b"}, want: []string{"c", "a", "b"}},
+		{name: "mixed", in: []string{"b", "a", "b", "c", "a"}, want: []string{"b", "a", "c"}},
+	}
+	for _, tt := range tests {
+		got := Unique(tt.in)
+		if (got == nil) != (tt.want == nil) || !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Unique() = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+	in := []string{"b", "a", "b"}
+	Unique(in)
+	if !slices.Equal(in, []string{"b", "a", "b"}) {
+		t.Errorf("Unique() changed its input to %q", in)
+	}
+} // TestUnique
+
+func TestUniqueFunc(t *testing.T) {
+	type row struct {
+		id     int
+		fields []string
+	}
+	rows := []row{{1, []string{"x"}}, {2, nil}, {1, []string{"y"}}, {3, nil}, {2, nil}}
+	got := UniqueFunc(rows, func(r row) int { return r.id })
+	ids := MapSlice(got, func(r row) int { return r.id })
+	if !slices.Equal(ids, []int{1, 2, 3}) || got[0].fields[0] != "x" {
+		t.Errorf("UniqueFunc() = %v, want the first row with each id", got)
+	}
+	if got := UniqueFunc(nil, func(r row) int { return r.id }); got != nil {
+		t.Errorf("UniqueFunc(nil) = %v, want nil", got)
+	}
+} // TestUniqueFunc
+
+func TestFirstAndLast(t *testing.T) {
+	if got, ok := First([]int{3, 4, 5}); got != 3 || !ok {
+		t.Errorf("First() = %d, %v, want 3, true", got, ok)
+	}
+	if got, ok := Last([]int{3, 4, 5}); got != 5 || !ok {
+		t.Errorf("Last() = %d, %v, want 5, true", got, ok)
+	}
+	if got, ok := First([]string{"only"}); got != "only" || !ok {
+		t.Errorf("First() = %q, %v, want \"only\", true", got, ok)
+	}
+	if got, ok := Last([]string{"only"}); got != "only" || !ok {
+		t.Errorf("Last() = %q, %v, want \"only\", true", got, ok)
+	}
+	if got, ok := First([]int{}); got != 0 || ok {
+		t.Errorf("First(empty) = %d, %v, want 0, false", got, ok)
+	}
+	if got, ok := Last[string](nil); got != "" || ok {
+		t.Errorf("Last(nil) = %q, %v, want \"\", false", got, ok)
+	}
+	if got, ok := First[*int](nil); got != nil || ok {
+		t.Errorf("First(nil) = %v, %v, want nil, false", got, ok)
+	}
+} // TestFirstAndLast
+
+func TestContainsAndIndexOf(t *testing.T) {
+	s := []string{"a", "b", "a"}
+	tests := []struct {
+		v     string
+		index int
+	}{
+		{v: "a", index: 0},
+		{v: "b", index: 1},
+		{v: "c", index: -1},
+	}
+	for _, tt := range tests {
+		if got := IndexOf(s, tt.v); got != tt.index {
+			t.Errorf("IndexOf(%q) = %d, want %d", tt.v, got, tt.index)
+		}
+		if got := Contains(s, tt.v); got != (tt.index >= 0) {
+			t.Errorf("Contains(%q) = %v, want %v", tt.v, got, tt.index >= 0)
+		}
+	}
+	if got := IndexOf[int](nil, 0); got != -1 {
+		t.Errorf("IndexOf(nil) = %d, want -1", got)
+	}
+	if allocs := testing.AllocsPerRun(10, func() { IndexOf(s, "c") }); allocs != 0 {
+		t.Errorf("IndexOf() allocates %v times, want none", allocs)
+	}
+} // TestContainsAndIndexOf
+
+func TestContainsFunc(t *testing.T) {
+	rows := [][]string{{"a"}, {"b", "c"}}
+	isEmpty := func(row []string) bool { return len(row) == 0 }
+	if ContainsFunc(rows, isEmpty) {
+		t.Error("ContainsFunc() = true, want false as no row is empty")
+	}
+	if !ContainsFunc(append(rows, nil), isEmpty) {
+		t.Error("ContainsFunc() = false, want true for an empty row")
+	}
+	if ContainsFunc(nil, isEmpty) {
+		t.Error("ContainsFunc(nil) = true, want false")
+	}
+} // TestContainsFunc
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
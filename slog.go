@@ -0,0 +1,236 @@
+// File: slog.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+)
+
+// NewSlogHandler returns a log/slog handler that logs each record using the
+// global log, so that slog can be used as the front end to the logging set
+// up by veil. Records below the given logging `level` are discarded.
+//
+// Record levels are mapped onto zerolog levels: slog.LevelDebug and above
+// to zerolog.DebugLevel, slog.LevelInfo and above to zerolog.InfoLevel, and
+// so on, with anything below slog.LevelDebug mapped to zerolog.TraceLevel.
+// Attributes become fields of the log entry, and groups become nested
+// objects; both are honored whether added by slog.Logger.With and
+// slog.Logger.WithGroup or given with the record.
+//
+// The global log is looked up for each record, so the handler follows any
+// later changes to the global log.
+func NewSlogHandler(level zerolog.Level) slog.Handler {
+	return &slogHandler{level: level}
+} // NewSlogHandler
+
+// SetGlobalSlogToFile sets up the global log like SetGlobalZerologToFile
+// does, and then makes a handler returned by NewSlogHandler the handler of
+// slog's default logger. The returned io.Closer closes the log file.
+//
+// Note that, as with any call to slog.SetDefault, output from the standard
+// library's log package then also goes through slog, and so to the log file.
+func SetGlobalSlogToFile(logName string, level zerolog.Level) (io.Closer, error) {
+	closer, err := ConfigureGlobalZerolog(WithFile(logName), WithLevel(level))
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(slog.New(NewSlogHandler(level)))
+	return closer, nil
+} // SetGlobalSlogToFile
+
+// slogHandler is a slog.Handler that logs using the global log.
+type slogHandler struct {
+	level zerolog.Level
+	// goas holds the groups and attributes added by WithGroup and
+	// WithAttrs, in the order in which they were added
+	goas []groupOrAttrs
+}
+
+// groupOrAttrs is either the name of a group, or some attributes.
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// slogCallerSkip is the number of stack frames to skip, from
+// slogHandler.Handle, to reach the caller of a slog logging function:
+// Handle itself, the slog logger's internal log method, and the
+// logging function, e.g., slog.Logger.Info.
+const slogCallerSkip = 3
+
+// Enabled reports whether records at the given slog `level` are logged.
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	zlevel := zerologLevel(level)
+	return zlevel >= h.level &&
+		zlevel >= zerolog.GlobalLevel() &&
+		zlevel >= GlobalLogger().GetLevel()
+} // Enabled
+
+// Handle logs record `r` as a single log entry.
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.Enabled(ctx, r.Level) {
+		return nil
+	}
+	logger := GlobalLogger()
+	e := logger.WithLevel(zerologLevel(r.Level)).CallerSkipFrame(slogCallerSkip)
+	if e == nil {
+		return nil
+	}
+	// split the groups and attributes into one level of nesting per group,
+	// with the record's own attributes in the innermost level
+	groups := []string{""}
+	levels := [][]slog.Attr{nil}
+	for _, goa := range h.goas {
+		if goa.group != "" {
+			groups = append(groups, goa.group)
+			levels = append(levels, nil)
+		} else {
+			levels[len(levels)-1] = append(levels[len(levels)-1], goa.attrs...)
+		}
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		levels[len(levels)-1] = append(levels[len(levels)-1], a)
+		return true
+	})
+	// build the nested objects from the innermost level outwards,
+	// leaving out any groups that end up empty
+	var inner *zerolog.Event
+	for i := len(levels) - 1; i >= 0; i-- {
+		outer := e
+		if i > 0 {
+			outer = zerolog.Dict()
+		}
+		n := addSlogAttrs(outer, levels[i])
+		if inner != nil {
+			outer.Dict(groups[i+1], inner)
+			n++
+		}
+		inner = nil
+		if n > 0 && i > 0 {
+			inner = outer
+		}
+	}
+	e.Msg(r.Message)
+	return nil
+} // Handle
+
+// WithAttrs returns a handler that adds `attrs` to every record.
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.with(groupOrAttrs{attrs: attrs})
+} // WithAttrs
+
+// WithGroup returns a handler that nests all further attributes
+// in the group `name`.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(groupOrAttrs{group: name})
+} // WithGroup
+
+// with returns a copy of the handler with `goa` added.
+func (h *slogHandler) with(goa groupOrAttrs) *slogHandler {
+	h2 := *h
+	h2.goas = make([]groupOrAttrs, len(h.goas)+1)
+	copy(h2.goas, h.goas)
+	h2.goas[len(h.goas)] = goa
+	return &h2
+} // with
+
+// zerologLevel returns the zerolog logging level for the slog `level`.
+func zerologLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zerolog.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zerolog.WarnLevel
+	case level >= slog.LevelInfo:
+		return zerolog.InfoLevel
+	case level >= slog.LevelDebug:
+		return zerolog.DebugLevel
+	default:
+		return zerolog.TraceLevel
+	}
+} // zerologLevel
+
+// addSlogAttrs adds each of `attrs` to `e` as a field,
+// and returns the number of fields that were added.
+func addSlogAttrs(e *zerolog.Event, attrs []slog.Attr) (n int) {
+	for _, a := range attrs {
+		n += addSlogAttr(e, a)
+	}
+	return n
+} // addSlogAttrs
+
+// addSlogAttr adds `a` to `e` as a field, following the rules for
+// slog handlers: empty attributes and empty groups are ignored, and
+// the attributes of a group with an empty key are added inline.
+// It returns the number of fields that were added.
+func addSlogAttr(e *zerolog.Event, a slog.Attr) int {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return 0
+	}
+	v := a.Value
+	switch v.Kind() {
+	case slog.KindString:
+		e.Str(a.Key, v.String())
+	case slog.KindInt64:
+		e.Int64(a.Key, v.Int64())
+	case slog.KindUint64:
+		e.Uint64(a.Key, v.Uint64())
+	case slog.KindFloat64:
+		e.Float64(a.Key, v.Float64())
+	case slog.KindBool:
+		e.Bool(a.Key, v.Bool())
+	case slog.KindDuration:
+		e.Dur(a.Key, v.Duration())
+	case slog.KindTime:
+		e.Time(a.Key, v.Time())
+	case slog.KindGroup:
+		if a.Key == "" {
+			return addSlogAttrs(e, v.Group())
+		}
+		d := zerolog.Dict()
+		if addSlogAttrs(d, v.Group()) == 0 {
+			return 0
+		}
+		e.Dict(a.Key, d)
+	default:
+		if err, ok := v.Any().(error); ok {
+			e.AnErr(a.Key, err)
+		} else {
+			e.Interface(a.Key, v.Any())
+		}
+	}
+	return 1
+} // addSlogAttr
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
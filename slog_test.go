@@ -0,0 +1,144 @@
+// File: slog_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSlogHandlerConsole(t *testing.T) {
+	resetGlobalLog(t)
+	var buff bytes.Buffer
+	SetGlobalZerologToWriter(&buff, zerolog.InfoLevel, false)
+	slog.New(NewSlogHandler(zerolog.InfoLevel)).Info("msg", "k", "v")
+	if !strings.Contains(buff.String(), "msg") || !strings.Contains(buff.String(), "k=v") {
+		t.Errorf("log = %q, want the message and k=v", buff.String())
+	}
+	if !strings.Contains(buff.String(), "slog_test.go:") {
+		t.Errorf("log = %q, want the caller of Info", buff.String())
+	}
+} // TestSlogHandlerConsole
+
+func TestSlogHandlerLevels(t *testing.T) {
+	resetGlobalLog(t)
+	var buff bytes.Buffer
+	SetGlobalZerologToWriter(&buff, zerolog.TraceLevel, true)
+	logger := slog.New(NewSlogHandler(zerolog.DebugLevel))
+	logger.Log(context.Background(), slog.LevelDebug-4, "too low")
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Log(context.Background(), slog.LevelWarn+2, "still warn")
+	logger.Error("error")
+	var levels []string
+	for _, entry := range slogEntries(t, &buff) {
+		levels = append(levels, entry["level"].(string)+":"+entry["message"].(string))
+	}
+	want := "debug:debug info:info warn:warn warn:still warn error:error"
+	if strings.Join(levels, " ") != want {
+		t.Errorf("levels = %q, want %q", levels, want)
+	}
+} // TestSlogHandlerLevels
+
+func TestSlogHandlerGroups(t *testing.T) {
+	resetGlobalLog(t)
+	var buff bytes.Buffer
+	SetGlobalZerologToWriter(&buff, zerolog.InfoLevel, true)
+	logger := slog.New(NewSlogHandler(zerolog.InfoLevel)).
+		With("service", "billing").
+		WithGroup("request").
+		With("id", 7)
+	logger.Info("handled", "status", 200, slog.Group("user", "name", "ann"))
+	logger.WithGroup("empty").Info("no attributes", slog.Group("none"))
+	entries := slogEntries(t, &buff)
+	if len(entries) != 2 {
+		t.Fatalf("log = %q, want two entries", buff.String())
+	}
+	got, _ := json.Marshal(entries[0])
+	want := `{"level":"info","message":"handled","request":{"id":7,"status":200,` +
+		`"user":{"name":"ann"}},"service":"billing"}`
+	if string(got) != want {
+		t.Errorf("entry = %s, want %s", got, want)
+	}
+	got, _ = json.Marshal(entries[1])
+	want = `{"level":"info","message":"no attributes","request":{"id":7},` +
+		`"service":"billing"}`
+	if string(got) != want {
+		t.Errorf("entry = %s, want %s", got, want)
+	}
+} // TestSlogHandlerGroups
+
+// slogEntries returns the JSON log entries in `buff`, one per line,
+// without their time and caller fields.
+func slogEntries(t *testing.T, buff *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buff.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		delete(entry, "time")
+		delete(entry, "caller")
+		entries = append(entries, entry)
+	}
+	return entries
+} // slogEntries
+
+func TestSetGlobalSlogToFile(t *testing.T) {
+	resetGlobalLog(t)
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	logName := filepath.Join(t.TempDir(), "app.log")
+	closer, err := SetGlobalSlogToFile(logName, zerolog.InfoLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.Info("through slog", "k", "v")
+	slog.Debug("below the level")
+	SetGlobalZerologNop()
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "through slog") || !strings.Contains(string(data), "k=v") {
+		t.Errorf("log file = %q, want the slog record", data)
+	}
+	if strings.Contains(string(data), "below the level") {
+		t.Errorf("log file = %q, has a record below the level", data)
+	}
+} // TestSetGlobalSlogToFile
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
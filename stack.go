@@ -0,0 +1,96 @@
+// File: stack.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+
+	pkgerr "github.com/pkg/errors"
+)
+
+// WrapStack annotates `err` with the message `msg`, like pkg/errors' Wrap
+// does, and records the stack trace of its caller. It returns nil if `err`
+// is nil, so it can be used unconditionally.
+//
+// The stack trace starts at the call to WrapStack, and is in the form that
+// the global log's stack marshaler expects, so logging the error with
+// Stack() logs a clean stack trace:
+//
+//	```go
+//	if err := load(name); err != nil {
+//	    log.Error().Stack().Err(veil.WrapStack(err, "cannot load config")).Msg("")
+//	}
+//
+// The returned error unwraps to `err`, so errors.Is and errors.As still work.
+func WrapStack(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(2, pcs[:])
+	return &stackError{
+		error: pkgerr.WithMessage(err, msg),
+		stack: pcs[:n],
+	}
+} // WrapStack
+
+// maxStackDepth is the maximum number of frames recorded by WrapStack.
+const maxStackDepth = 32
+
+// stackError is an error annotated with the stack trace where it was wrapped.
+type stackError struct {
+	error
+	stack []uintptr
+}
+
+// Unwrap returns the wrapped error.
+func (e *stackError) Unwrap() error {
+	return e.error
+} // Unwrap
+
+// StackTrace returns the recorded stack trace, as pkg/errors does.
+func (e *stackError) StackTrace() pkgerr.StackTrace {
+	frames := make(pkgerr.StackTrace, len(e.stack))
+	for i, pc := range e.stack {
+		frames[i] = pkgerr.Frame(pc)
+	}
+	return frames
+} // StackTrace
+
+// Format formats the error like pkg/errors does,
+// i.e., `%+v` also prints the stack trace.
+func (e *stackError) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		fmt.Fprintf(s, "%+v", e.error)
+		e.StackTrace().Format(s, verb)
+	case verb == 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	default:
+		_, _ = io.WriteString(s, e.Error())
+	}
+} // Format
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
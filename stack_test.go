@@ -0,0 +1,78 @@
+// File: stack_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestWrapStack(t *testing.T) {
+	if err := WrapStack(nil, "context"); err != nil {
+		t.Errorf("WrapStack(nil) = %v, want nil", err)
+	}
+
+	resetGlobalLog(t)
+	var buff bytes.Buffer
+	SetGlobalZerologToWriter(&buff, zerolog.InfoLevel, true)
+	_, _, line, _ := runtime.Caller(0)
+	err := WrapStack(io.EOF, "cannot read")
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("WrapStack() = %v, want an error wrapping io.EOF", err)
+	}
+	if err.Error() != "cannot read: EOF" {
+		t.Errorf("WrapStack().Error() = %q, want %q", err.Error(), "cannot read: EOF")
+	}
+	l := GlobalLogger()
+	l.Error().Stack().Err(err).Msg("")
+
+	var entry struct {
+		Stack []map[string]string `json:"stack"`
+	}
+	if err := json.Unmarshal(buff.Bytes(), &entry); err != nil {
+		t.Fatalf("cannot parse %q: %v", buff.String(), err)
+	}
+	if len(entry.Stack) == 0 {
+		t.Fatalf("log entry = %q, want a stack", buff.String())
+	}
+	top := entry.Stack[0]
+	want := map[string]string{
+		"source": "stack_test.go",
+		"line":   strconv.Itoa(line + 1),
+		"func":   "TestWrapStack",
+	}
+	for key, value := range want {
+		if top[key] != value {
+			t.Errorf("top frame %s = %q, want %q", key, top[key], value)
+		}
+	}
+} // TestWrapStack
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,78 @@
+//go:build !windows && !plan9 && !binary_log
+
+// File: syslog.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"io"
+	"log/syslog"
+
+	"github.com/rs/zerolog"
+)
+
+// SetGlobalZerologToSyslog sets up the global log with the given logging
+// `level` to write to the local syslog daemon, with each message tagged
+// with `tag`, e.g., the program's name. The log entries are written as JSON,
+// with the file and line number where they were created but without a
+// timestamp, as the syslog daemon adds its own.
+//
+// zerolog logging levels are mapped to syslog priorities as follows:
+// trace and debug to LOG_DEBUG, info to LOG_INFO, warn to LOG_WARNING,
+// error to LOG_ERR, fatal to LOG_EMERG, and panic to LOG_CRIT. Entries
+// logged without a level have LOG_INFO priority. The syslog facility is
+// LOG_USER.
+//
+// The returned io.Closer closes the connection to the syslog daemon. If the
+// syslog daemon cannot be reached then the global log is left unchanged.
+//
+// Syslog is not supported on Windows or Plan 9, where this function always
+// returns an error.
+func SetGlobalZerologToSyslog(tag string, level zerolog.Level) (io.Closer, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	logger := zerolog.New(syslogWriter{zerolog.SyslogLevelWriter(w)}).
+		With().Caller().Logger()
+	closer := &logCloser{file: w}
+	installGlobalZerolog(logger, level, closer)
+	return closer, nil
+} // SetGlobalZerologToSyslog
+
+// syslogWriter is a zerolog.LevelWriter that writes to syslog, like the
+// one returned by zerolog.SyslogLevelWriter, except that trace entries are
+// written at debug priority rather than being dropped.
+type syslogWriter struct {
+	zerolog.LevelWriter
+}
+
+// WriteLevel writes `p` to syslog with the priority for `level`.
+func (w syslogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level == zerolog.TraceLevel {
+		level = zerolog.DebugLevel
+	}
+	return w.LevelWriter.WriteLevel(level, p)
+} // WriteLevel
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,41 @@
+//go:build windows || plan9 || binary_log
+
+// File: syslog_stub.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"errors"
+	"io"
+
+	"github.com/rs/zerolog"
+)
+
+// SetGlobalZerologToSyslog would set up the global log to write to the
+// local syslog daemon, but syslog is not supported on this platform, so
+// it always returns an error and leaves the global log unchanged.
+func SetGlobalZerologToSyslog(tag string, level zerolog.Level) (io.Closer, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+} // SetGlobalZerologToSyslog
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
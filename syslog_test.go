@@ -0,0 +1,91 @@
+//go:build !windows && !plan9 && !binary_log
+
+// File: syslog_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// fakeSyslog is a zerolog.SyslogWriter that records each message
+// written to it, prefixed by the name of its priority.
+type fakeSyslog struct {
+	messages []string
+}
+
+func (s *fakeSyslog) record(priority, m string) error {
+	s.messages = append(s.messages, priority+": "+strings.TrimSpace(m))
+	return nil
+}
+
+func (s *fakeSyslog) Write(p []byte) (int, error) { return len(p), s.record("write", string(p)) }
+func (s *fakeSyslog) Debug(m string) error        { return s.record("debug", m) }
+func (s *fakeSyslog) Info(m string) error         { return s.record("info", m) }
+func (s *fakeSyslog) Warning(m string) error      { return s.record("warning", m) }
+func (s *fakeSyslog) Err(m string) error          { return s.record("err", m) }
+func (s *fakeSyslog) Emerg(m string) error        { return s.record("emerg", m) }
+func (s *fakeSyslog) Crit(m string) error         { return s.record("crit", m) }
+
+func TestSyslogWriterPriorities(t *testing.T) {
+	var fake fakeSyslog
+	logger := zerolog.New(syslogWriter{zerolog.SyslogLevelWriter(&fake)}).
+		Level(zerolog.TraceLevel)
+	logger.Trace().Msg("t")
+	logger.Debug().Msg("d")
+	logger.Info().Msg("i")
+	logger.Warn().Msg("w")
+	logger.Error().Msg("e")
+	logger.Log().Msg("none")
+	want := []string{
+		`debug: {"level":"trace","message":"t"}`,
+		`debug: {"level":"debug","message":"d"}`,
+		`info: {"level":"info","message":"i"}`,
+		`warning: {"level":"warn","message":"w"}`,
+		`err: {"level":"error","message":"e"}`,
+		`info: {"message":"none"}`,
+	}
+	if strings.Join(fake.messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("syslog messages =\n%s\nwant\n%s",
+			strings.Join(fake.messages, "\n"), strings.Join(want, "\n"))
+	}
+} // TestSyslogWriterPriorities
+
+func TestSetGlobalZerologToSyslog(t *testing.T) {
+	resetGlobalLog(t)
+	closer, err := SetGlobalZerologToSyslog("veil-test", zerolog.InfoLevel)
+	if err != nil {
+		t.Skipf("no syslog daemon to log to: %v", err)
+	}
+	l := GlobalLogger()
+	l.Warn().Msg("veil syslog test")
+	SetGlobalZerologNop()
+	if err := closer.Close(); err != nil {
+		t.Error(err)
+	}
+} // TestSetGlobalZerologToSyslog
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
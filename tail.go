@@ -0,0 +1,241 @@
+// File: tail.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// TailLog returns the last `n` lines of the log file named `logName`, or
+// all of its lines if it has fewer than `n`, without their line endings
+// ("\n" or "\r\n"), e.g., for a status command that shows recent activity:
+//
+//	```go
+//	lines, err := veil.TailLog("app.log", 20)
+//	if err != nil {
+//	    return err
+//	}
+//	for _, line := range lines {
+//	    fmt.Println(line)
+//	}
+//
+// The file is read backwards from its end, a chunk at a time, until enough
+// lines have been read, so only the end of a large file is read. A final
+// line that does not end with a newline is still returned, as the last
+// line, while an empty file, or an `n` of zero or less, gives no lines,
+// i.e., a nil slice.
+func TailLog(logName string, n int) ([]string, error) {
+	f, err := os.Open(logName)
+	if err != nil {
+		return nil, err
+	}
+	defer CloseIgnore(f)
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 || info.Size() == 0 {
+		return nil, nil
+	}
+	var chunks [][]byte // from the end of the file backwards
+	newlines, wanted := 0, n
+	pos := info.Size()
+	for pos > 0 && newlines < wanted {
+		chunk := make([]byte, min(tailChunkSize, pos))
+		pos -= int64(len(chunk))
+		if _, err := f.ReadAt(chunk, pos); err != nil {
+			return nil, err
+		}
+		if chunks == nil && chunk[len(chunk)-1] == '\n' {
+			// the final newline ends the last line, rather than starting one
+			wanted++
+		}
+		newlines += bytes.Count(chunk, []byte{'\n'})
+		chunks = append(chunks, chunk)
+	}
+	// joined once, as prepending each chunk would copy the tail each time
+	var tail strings.Builder
+	tail.Grow(int(info.Size() - pos))
+	for i := len(chunks) - 1; i >= 0; i-- {
+		tail.Write(chunks[i])
+	}
+	text := strings.TrimSuffix(tail.String(), "\n")
+	lines := strings.Split(text, "\n")
+	lines = lines[max(0, len(lines)-n):]
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines, nil
+} // TailLog
+
+// FollowLog streams the lines appended to the log file named `logName`,
+// like `tail -f` does, until `ctx` is done, when the returned channel is
+// closed. Only lines appended after FollowLog is called are sent, and only
+// complete lines, without their line endings ("\n" or "\r\n"):
+//
+//	```go
+//	ctx, cancel := context.WithCancel(context.Background())
+//	defer cancel()
+//	lines, err := veil.FollowLog(ctx, "app.log")
+//	if err != nil {
+//	    return err
+//	}
+//	for line := range lines {
+//	    fmt.Println(line)
+//	}
+//
+// The file is polled for new lines, a few times a second. When the file is
+// rotated, i.e., `logName` is renamed, and a new file is created in its
+// place, as by SetGlobalZerologRotating, the rest of the old file is read,
+// and then the new file is followed from its start. A file that shrinks is
+// taken to have been truncated, and is followed from its start.
+//
+// Each line is sent at most once, but lines can be missed: those written
+// to a file that is truncated between polls, those written to the old file
+// after it was rotated out and read, and a final line of the old file that
+// does not end with a newline.
+//
+// An error is returned if the file cannot be opened; errors afterwards, such
+// as the file being missing for a moment while it is rotated, are ignored,
+// and the file is polled again. The channel is unbuffered, so a slow reader
+// holds up the following of the file, but no lines are lost while it does.
+func FollowLog(ctx context.Context, logName string) (<-chan string, error) {
+	f, err := os.Open(logName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		CloseIgnore(f)
+		return nil, err
+	}
+	lines := make(chan string)
+	go (&follower{name: logName, file: f, lines: lines}).follow(ctx)
+	return lines, nil
+} // FollowLog
+
+// followPollInterval is how often FollowLog polls the file it follows.
+const followPollInterval = 200 * time.Millisecond
+
+// follower follows a log file, for FollowLog.
+type follower struct {
+	name    string
+	file    *os.File
+	reader  *bufio.Reader
+	partial []byte
+	lines   chan<- string
+}
+
+// follow sends the lines appended to the log file until `ctx` is done,
+// and then closes the log file and the `lines` channel.
+func (fl *follower) follow(ctx context.Context) {
+	defer close(fl.lines)
+	defer func() {
+		CloseIgnore(fl.file)
+	}()
+	fl.reader = bufio.NewReader(fl.file)
+	ticker := time.NewTicker(followPollInterval)
+	defer ticker.Stop()
+	for {
+		if !fl.sendLines(ctx) || !fl.checkFile(ctx) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+} // follow
+
+// sendLines sends each complete line that can be read from the log file,
+// returning false if `ctx` is done before all of them are sent.
+func (fl *follower) sendLines(ctx context.Context) bool {
+	for {
+		chunk, err := fl.reader.ReadBytes('\n')
+		fl.partial = append(fl.partial, chunk...)
+		if err != nil {
+			// most likely io.EOF, which leaves the partial line for later
+			return true
+		}
+		line := strings.TrimSuffix(string(fl.partial[:len(fl.partial)-1]), "\r")
+		fl.partial = fl.partial[:0]
+		select {
+		case fl.lines <- line:
+		case <-ctx.Done():
+			return false
+		}
+	}
+} // sendLines
+
+// checkFile switches to the new log file if the log file has been rotated,
+// after sending the rest of the old file's lines, or starts reading the log
+// file again from its start if it has been truncated. It returns false if
+// `ctx` is done before the rest of the old file's lines are sent.
+func (fl *follower) checkFile(ctx context.Context) bool {
+	info, err := os.Stat(fl.name)
+	if err != nil {
+		return true
+	}
+	current, err := fl.file.Stat()
+	if err != nil {
+		return true
+	}
+	if !os.SameFile(info, current) {
+		f, err := os.Open(fl.name)
+		if err != nil {
+			return true
+		}
+		if !fl.sendLines(ctx) {
+			CloseIgnore(f)
+			return false
+		}
+		CloseIgnore(fl.file)
+		fl.file = f
+		fl.restart()
+		return fl.sendLines(ctx)
+	}
+	// reading until end of file leaves nothing buffered by the reader
+	if pos, err := fl.file.Seek(0, io.SeekCurrent); err == nil && info.Size() < pos {
+		if _, err := fl.file.Seek(0, io.SeekStart); err == nil {
+			fl.restart()
+		}
+	}
+	return true
+} // checkFile
+
+// restart starts reading the log file afresh, from its current position.
+func (fl *follower) restart() {
+	fl.reader.Reset(fl.file)
+	fl.partial = fl.partial[:0]
+} // restart
+
+// tailChunkSize is the size of the chunks that TailLog reads a file in.
+const tailChunkSize = 4096
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
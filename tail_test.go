@@ -0,0 +1,177 @@
+// File: tail_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTailLog(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, contents string
+		n              int
+		want           []string
+	}{
+		{name: "last lines", contents: "a\nb\nc\nd\n", n: 2, want: []string{"c", "d"}},
+		{name: "fewer lines", contents: "a\nb\n", n: 5, want: []string{"a", "b"}},
+		{name: "no final newline", contents: "a\nb\nc", n: 2, want: []string{"b", "c"}},
+		{name: "line endings", contents: "a\r\nb\r\n", n: 2, want: []string{"a", "b"}},
+		{name: "empty lines", contents: "a\n\n\n", n: 2, want: []string{"", ""}},
+		{name: "empty file", contents: "", n: 3, want: nil},
+		{name: "no lines wanted", contents: "a\n", n: 0, want: nil},
+	}
+	for i, tt := range tests {
+		logName := filepath.Join(dir, fmt.Sprintf("%d.log", i))
+		if err := os.WriteFile(logName, []byte(tt.contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := TailLog(logName, tt.n)
+		if err != nil || !slices.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+			t.Errorf("%s: TailLog(%d) = %q, %v, want %q", tt.name, tt.n, got, err, tt.want)
+		}
+	}
+	if _, err := TailLog(filepath.Join(dir, "missing.log"), 1); !os.IsNotExist(err) {
+		t.Errorf("TailLog() of a missing file = %v, want a not-exist error", err)
+	}
+} // TestTailLog
+
+func TestTailLogLarge(t *testing.T) {
+	var contents strings.Builder
+	var all []string
+	for i := 0; contents.Len() < 5*tailChunkSize; i++ {
+		line := fmt.Sprintf("log entry number %d", i)
+		all = append(all, line)
+		contents.WriteString(line + "\n")
+	}
+	logName := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(logName, []byte(contents.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// lines from more than one chunk, and then every line
+	for _, n := range []int{300, len(all), len(all) + 10} {
+		got, err := TailLog(logName, n)
+		want := all[max(0, len(all)-n):]
+		if err != nil || !slices.Equal(got, want) {
+			t.Errorf("TailLog(%d) = %d lines, %v, want the last %d lines",
+				n, len(got), err, len(want))
+		}
+	}
+} // TestTailLogLarge
+
+func BenchmarkTailLog(b *testing.B) {
+	// a 16 MiB log file, all of which is read
+	line := strings.Repeat("x", 63) + "\n"
+	contents := strings.Repeat(line, 16<<20/len(line))
+	logName := filepath.Join(b.TempDir(), "app.log")
+	if err := os.WriteFile(logName, []byte(contents), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	n := strings.Count(contents, "\n")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if lines, err := TailLog(logName, n); err != nil || len(lines) != n {
+			b.Fatalf("TailLog() = %d lines, %v, want %d lines", len(lines), err, n)
+		}
+	}
+} // BenchmarkTailLog
+
+func TestFollowLog(t *testing.T) {
+	logName := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, logName, "already there\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines, err := FollowLog(ctx, logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appendLog(t, logName, "first\r\nsecond\npart")
+	assertFollowed(t, lines, "first", "second")
+	appendLog(t, logName, "ial\n")
+	assertFollowed(t, lines, "partial")
+
+	// rotated, as by a rotating writer: the rest of the old file is read,
+	// and then the new file from its start
+	if err := os.Rename(logName, logName+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLog(t, logName+".1", "last of the old file\n")
+	appendLog(t, logName, "first of the new file\n")
+	assertFollowed(t, lines, "last of the old file", "first of the new file")
+
+	cancel()
+	select {
+	case line, ok := <-lines:
+		if ok {
+			t.Errorf("received %q after cancelling, want the channel closed", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("channel not closed after cancelling")
+	}
+
+	if _, err := FollowLog(context.Background(), logName+".missing"); err == nil {
+		t.Error("FollowLog() of a missing file succeeded")
+	}
+} // TestFollowLog
+
+// appendLog appends `text` to the log file named `logName`,
+// creating the file if need be.
+func appendLog(t *testing.T, logName, text string) {
+	t.Helper()
+	f, err := os.OpenFile(logName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+} // appendLog
+
+// assertFollowed fails the test `t` unless the lines received next from
+// `lines` are `want`, in order.
+func assertFollowed(t *testing.T, lines <-chan string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case line := <-lines:
+			if line != w {
+				t.Errorf("received %q, want %q", line, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no line received, want %q", w)
+		}
+	}
+} // assertFollowed
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
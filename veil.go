@@ -27,6 +27,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sync"
 	"time"
 
@@ -42,34 +43,18 @@ import (
 //
 // When this function returns, `stdin` and `stdout` are restored to the
 // streams that they originally referred to.
-func CaptureOutput(f func()) (string, error) {
-	reader, writer, err := os.Pipe()
-	if err != nil {
-		return "", err
-	}
-	stdout := os.Stdout
-	stderr := os.Stderr
-	defer func() {
-		os.Stdout = stdout
-		os.Stderr = stderr
-	}()
-	os.Stdout = writer
-	os.Stderr = writer
-	wg := new(sync.WaitGroup)
-	wg.Add(1)
-	out := make(chan string)
-	go func() {
-		var buff bytes.Buffer
-		wg.Done()
-		// do nothing if an error occurs
-		// because there is nothing we can do
-		io.Copy(&buff, reader) // nolint:errcheck
-		out <- buff.String()
-	}()
-	wg.Wait()
-	f()
-	writer.Close()
-	return <-out, nil
+//
+// If `f` panics then the panic is recovered and returned as an error,
+// along with any output that `f` produced before it panicked.
+//
+// Captures are serialized: because the standard streams are shared by the
+// whole process, concurrent calls to CaptureOutput (or to any other capture
+// function) run one at a time rather than interfering with each other.
+// Consequently `f` must not itself call a capture function.
+func CaptureOutput(f func()) (output string, err error) {
+	return captureToPooled(func(buff *bytes.Buffer) error {
+		return captureTo(buff, f)
+	})
 } // CaptureOutput
 
 // FilePathInCwd returns the full path of the file named
@@ -93,6 +78,29 @@ func IgnoreUnused(vals ...interface{}) {
 	}
 }
 
+// IgnoreError hides the fact that `err` is deliberately not checked, which
+// keeps linters such as errcheck quiet.
+func IgnoreError(err error) {
+	_ = err
+} // IgnoreError
+
+// CloseIgnore closes `c`, ignoring any error. It is meant to be deferred,
+// e.g., `defer veil.CloseIgnore(f)`, where the error from closing does not
+// matter, such as for a file that was only read.
+func CloseIgnore(c io.Closer) {
+	IgnoreError(c.Close())
+} // CloseIgnore
+
+// CloseLog closes `c`, like CloseIgnore does, but logs any error from
+// closing it at debug level using the global log, so that the error is not
+// completely lost. It is meant to be deferred, e.g., `defer veil.CloseLog(f)`.
+func CloseLog(c io.Closer) {
+	if err := c.Close(); err != nil {
+		l := GlobalLogger()
+		l.Debug().CallerSkipFrame(1).Err(err).Msg("error closing")
+	}
+} // CloseLog
+
 // SetGlobalZerologToFile sets up the global log with
 // the given logging `level` to a file named `logName`.
 //
@@ -112,19 +120,84 @@ func IgnoreUnused(vals ...interface{}) {
 //	withStack := errors.WithStack(err)
 //	log.Error().Stack().Err(withStack).Msg("an error occurred")
 //
-// i.e., you need to wrap the error using github.com/pkg/errors.
+// i.e., you need to wrap the error using github.com/pkg/errors, or WrapStack.
+//
+// If the file named `logName` cannot be opened then the error is returned
+// and the global log is left unchanged.
 func SetGlobalZerologToFile(logName string, level zerolog.Level) (err error) {
-	var f *os.File
-	f, err = os.OpenFile(logName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
-	log.Logger = zerolog.New(zerolog.ConsoleWriter{
-		Out:        f,
-		TimeFormat: "Mon 02 Jan 2006, 15:04:05.000",
-	}).
-		With().Timestamp().Caller().Logger()
-	zerolog.SetGlobalLevel(level)
-	zerolog.TimeFieldFormat = time.RFC3339Nano
-	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
-	return err
+	return SetGlobalZerologToFilePerm(logName, level, 0o644)
 } // SetGlobalZerologToFile
 
+// consoleTimeFormat is the default time format of the timestamps
+// shown in human-friendly console formatted log entries.
+const consoleTimeFormat = "Mon 02 Jan 2006, 15:04:05.000"
+
+// GlobalLogger returns the global log, i.e., zerolog's `log.Logger`.
+//
+// Unlike reading `log.Logger` directly, this is safe to do while another
+// goroutine changes the global log, e.g., using ReconfigureGlobalZerolog.
+// Code that logs while the global log may be reconfigured should therefore
+// log through the logger returned by this function:
+//
+//	```go
+//	l := veil.GlobalLogger()
+//	l.Info().Msg("safe to log during a reconfiguration")
+func GlobalLogger() zerolog.Logger {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return log.Logger
+} // GlobalLogger
+
+// globalMu guards every change to the global log made by this package,
+// along with `globalCloser`.
+var globalMu sync.RWMutex
+
+// globalCloser is the io.Closer for the log file of the global log,
+// as installed by installGlobalZerolog.
+var globalCloser io.Closer = nopCloser{}
+
+// installGlobalZerolog makes `logger` the global log, with the given logging
+// `level`, and `closer` the io.Closer for its log file. The io.Closer for
+// the previous global log's file is returned, but is not closed.
+func installGlobalZerolog(
+	logger zerolog.Logger,
+	level zerolog.Level,
+	closer io.Closer,
+) (previous io.Closer) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	log.Logger = logger
+	zerolog.SetGlobalLevel(level)
+	setZerologFormats()
+	previous, globalCloser = globalCloser, closer
+	return previous
+} // installGlobalZerolog
+
+// swapGlobalLogger makes `logger` the global log, without changing
+// zerolog's global logging level or `globalCloser`, and returns the
+// previous global log.
+func swapGlobalLogger(logger zerolog.Logger) (previous zerolog.Logger) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	previous, log.Logger = log.Logger, logger
+	return previous
+} // swapGlobalLogger
+
+// setZerologFormats sets zerolog's process-wide formatting settings: the
+// RFC 3339 Nano timestamp format, and the marshaling of stack traces.
+//
+// zerolog has no per-logger equivalent of these settings. They are only
+// written when they differ from the values wanted, as writing them while
+// another goroutine is logging would be a data race.
+func setZerologFormats() {
+	if zerolog.TimeFieldFormat != time.RFC3339Nano {
+		zerolog.TimeFieldFormat = time.RFC3339Nano
+	}
+	// functions cannot be compared, other than by their code pointers
+	if reflect.ValueOf(zerolog.ErrorStackMarshaler).Pointer() !=
+		reflect.ValueOf(pkgerrors.MarshalStack).Pointer() {
+		zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
+	}
+} // setZerologFormats
+
 // vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,163 @@
+// File: veil_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestCaptureOutputPanic(t *testing.T) {
+	stdout, stderr := os.Stdout, os.Stderr
+	output, err := CaptureOutput(func() {
+		fmt.Print("partial ")
+		fmt.Fprint(os.Stderr, "output")
+		panic("mid-write")
+	})
+	if output != "partial output" {
+		t.Errorf("output = %q, want %q", output, "partial output")
+	}
+	if err == nil || !strings.Contains(err.Error(), "panicked: mid-write") {
+		t.Errorf("err = %v, want the recovered panic", err)
+	}
+	if os.Stdout != stdout || os.Stderr != stderr {
+		t.Error("the standard streams were not restored after the panic")
+	}
+} // TestCaptureOutputPanic
+
+func TestCaptureOutputConcurrent(t *testing.T) {
+	const n = 10
+	outputs := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outputs[i], errs[i] = CaptureOutput(func() {
+				for j := 0; j < 100; j++ {
+					fmt.Printf("capture %d\n", i)
+				}
+			})
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < n; i++ {
+		want := strings.Repeat(fmt.Sprintf("capture %d\n", i), 100)
+		if errs[i] != nil || outputs[i] != want {
+			t.Errorf("capture %d = %q, %v, want only its own output",
+				i, outputs[i], errs[i])
+		}
+	}
+} // TestCaptureOutputConcurrent
+
+func TestSetGlobalZerologToFileError(t *testing.T) {
+	resetGlobalLog(t)
+	var buff bytes.Buffer
+	SetGlobalZerologToWriter(&buff, zerolog.InfoLevel, true)
+	notDir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notDir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// a file cannot be opened inside a regular file, even by root
+	logName := filepath.Join(notDir, "app.log")
+	if err := SetGlobalZerologToFile(logName, zerolog.DebugLevel); err == nil {
+		t.Fatal("err = nil, want the error from opening the log file")
+	}
+	l := GlobalLogger()
+	l.Info().Msg("still here")
+	if !strings.Contains(buff.String(), "still here") {
+		t.Errorf("the global log was changed; its buffer = %q", buff.String())
+	}
+	if zerolog.GlobalLevel() != zerolog.InfoLevel {
+		t.Errorf("global level = %v, want it unchanged", zerolog.GlobalLevel())
+	}
+} // TestSetGlobalZerologToFileError
+
+// resetGlobalLog arranges for the global log to be made a nop logger, at
+// the global level from before the test, once the test `t` ends, closing
+// the log file of the global log that it replaces.
+func resetGlobalLog(t *testing.T) {
+	level := zerolog.GlobalLevel()
+	t.Cleanup(func() {
+		previous := installGlobalZerolog(NopLogger(), level, nopCloser{})
+		IgnoreError(previous.Close())
+	})
+} // resetGlobalLog
+
+// countingCloser is an io.Closer that counts the times it is closed,
+// and fails to close with `err`.
+type countingCloser struct {
+	closed int
+	err    error
+}
+
+func (c *countingCloser) Close() error {
+	c.closed++
+	return c.err
+}
+
+func TestCloseIgnore(t *testing.T) {
+	IgnoreError(nil)
+	IgnoreError(errors.New("ignored"))
+	c := &countingCloser{err: errors.New("cannot close")}
+	output, err := CaptureOutput(func() { CloseIgnore(c) })
+	if err != nil || output != "" || c.closed != 1 {
+		t.Errorf("CloseIgnore() output = %q, %v, closed %d times, want 1 quiet close",
+			output, err, c.closed)
+	}
+} // TestCloseIgnore
+
+func TestCloseLog(t *testing.T) {
+	var line int
+	events, err := CaptureLogEvents(zerolog.DebugLevel, func() {
+		CloseLog(&countingCloser{})
+		_, _, line, _ = runtime.Caller(0)
+		CloseLog(&countingCloser{err: errors.New("cannot close")})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("events = %v, want one for the failed close", events)
+	}
+	e := events[0]
+	if e["level"] != "debug" || e["error"] != "cannot close" {
+		t.Errorf("event = %v, want the error at debug level", e)
+	}
+	caller, _ := e["caller"].(string)
+	if want := fmt.Sprintf("veil_test.go:%d", line+1); !strings.HasSuffix(caller, want) {
+		t.Errorf("caller = %q, want the call of CloseLog, %s", caller, want)
+	}
+} // TestCloseLog
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,707 @@
+// File: zerolog.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ErrLogFellBackToStderr is returned, wrapped, when the global log is set
+// up to write to standard error because its log file could not be opened.
+var ErrLogFellBackToStderr = errors.New("logging to standard error instead")
+
+// SetGlobalZerologToFileOrStderr sets up the global log like
+// SetGlobalZerologToFile does, but if the log file cannot be opened then
+// the global log is set up to write to standard error instead, so that
+// logging keeps working on a misconfigured host.
+//
+// When that happens the returned error wraps both ErrLogFellBackToStderr
+// and the error from opening the log file, so that callers can decide
+// whether falling back counts as a failure:
+//
+//	```go
+//	err := veil.SetGlobalZerologToFileOrStderr("/var/log/app.log", level)
+//	if errors.Is(err, veil.ErrLogFellBackToStderr) {
+//	    log.Warn().Err(err).Msg("cannot open the log file")
+//	}
+func SetGlobalZerologToFileOrStderr(logName string, level zerolog.Level) error {
+	err := SetGlobalZerologToFile(logName, level)
+	if err == nil {
+		return nil
+	}
+	if _, stderrErr := ConfigureGlobalZerolog(WithLevel(level)); stderrErr != nil {
+		return stderrErr
+	}
+	return fmt.Errorf("%w: %w", ErrLogFellBackToStderr, err)
+} // SetGlobalZerologToFileOrStderr
+
+// SetGlobalZerologToFileWithCloser sets up the global log exactly like
+// SetGlobalZerologToFile does, but also returns an io.Closer for the
+// log file so that it can be closed when the program shuts down:
+//
+//	```go
+//	closer, err := veil.SetGlobalZerologToFileWithCloser("app.log", level)
+//	if err != nil {
+//	    return err
+//	}
+//	defer closer.Close()
+//
+// If the log file cannot be opened then the global log is left unchanged,
+// and a nil closer is returned along with the error.
+//
+// Closing the log file while the global log still refers to it is safe.
+// Any later log entries are dropped, and zerolog reports each failed write
+// on standard error, rather than causing a panic.
+func SetGlobalZerologToFileWithCloser(
+	logName string,
+	level zerolog.Level,
+) (io.Closer, error) {
+	return ConfigureGlobalZerolog(WithFile(logName), WithLevel(level))
+} // SetGlobalZerologToFileWithCloser
+
+// SetGlobalZerologToFileResolved sets up the global log exactly like
+// SetGlobalZerologToFileWithCloser does, but also returns the absolute path
+// of the log file, so that the program can report where it is logging to:
+//
+//	```go
+//	path, closer, err := veil.SetGlobalZerologToFileResolved("app.log", level)
+//	if err != nil {
+//	    return err
+//	}
+//	defer closer.Close()
+//	log.Info().Msgf("logging to %s", path)
+//
+// A leading "~" in `logName` is expanded, as it is by ExpandTilde, and a
+// relative `logName` is taken to be relative to the current working
+// directory. If the log file cannot be opened then the global log is left
+// unchanged, and an empty path and a nil closer are returned.
+func SetGlobalZerologToFileResolved(
+	logName string,
+	level zerolog.Level,
+) (absPath string, closer io.Closer, err error) {
+	if absPath, err = ExpandTilde(logName); err != nil {
+		return "", nil, err
+	}
+	if absPath, err = filepath.Abs(absPath); err != nil {
+		return "", nil, err
+	}
+	closer, err = ConfigureGlobalZerolog(WithFile(absPath), WithLevel(level))
+	if err != nil {
+		return "", nil, err
+	}
+	return absPath, closer, nil
+} // SetGlobalZerologToFileResolved
+
+// SetGlobalZerologToConsoleAndFile sets up the global log with the given
+// logging `level` to write to both standard error and a file named
+// `logName`. Log entries written to standard error are colored, if it is
+// a terminal, while those written to the file are not; otherwise the
+// logging is set up as it is by SetGlobalZerologToFile.
+//
+// The returned io.Closer closes the log file. If the log file cannot be
+// opened then the global log is left unchanged.
+func SetGlobalZerologToConsoleAndFile(
+	logName string,
+	level zerolog.Level,
+) (io.Closer, error) {
+	return ConfigureGlobalZerolog(
+		WithFile(logName), WithLevel(level), WithConsole())
+} // SetGlobalZerologToConsoleAndFile
+
+// SetGlobalZerologAuto sets up the global log with the given logging
+// `level` to a file named `logName`, and also to standard error if that is
+// a terminal, as determined by IsTerminal.
+//
+// When standard error is a terminal the log is set up as it is by
+// SetGlobalZerologToConsoleAndFile, with colored log entries on the
+// terminal. Otherwise, e.g., when the program runs as a service, the log
+// entries are only written to the file, without color.
+//
+// If the log file cannot be opened then the global log is left unchanged.
+func SetGlobalZerologAuto(logName string, level zerolog.Level) error {
+	opts := []LoggerOption{WithFile(logName), WithLevel(level)}
+	if IsTerminal(os.Stderr) {
+		opts = append(opts, WithConsole())
+	}
+	_, err := ConfigureGlobalZerolog(opts...)
+	return err
+} // SetGlobalZerologAuto
+
+// NopLogger returns a logger that discards every log entry, whatever its
+// level, e.g., as the default logger of a library, or for code under test
+// whose logging does not matter:
+//
+//	```go
+//	type Client struct {
+//	    Logger zerolog.Logger
+//	}
+//
+//	func NewClient() *Client {
+//	    return &Client{Logger: veil.NopLogger()}
+//	}
+//
+// Logging through it never fails, and never allocates,
+// as its entries are discarded before they are made.
+func NopLogger() zerolog.Logger {
+	return zerolog.New(io.Discard).Level(zerolog.Disabled)
+} // NopLogger
+
+// SetGlobalZerologNop makes the global log discard every log entry, as
+// NopLogger does. Unlike setting zerolog's global logging level to
+// zerolog.Disabled, this leaves other loggers, such as those returned by
+// NewFileLogger, logging as usual.
+//
+// The log file of the previous global log, if any, is closed once the
+// global log has been swapped, as ReconfigureGlobalZerolog does. Any error
+// from closing it is ignored, as there is no log left to report it to.
+func SetGlobalZerologNop() {
+	previous := installGlobalZerolog(NopLogger(), zerolog.GlobalLevel(), nopCloser{})
+	IgnoreError(previous.Close())
+} // SetGlobalZerologNop
+
+// SetGlobalZerologJSONToFile sets up the global log with the given
+// logging `level` to a file named `logName`, writing each log entry as a
+// single line of JSON rather than in a human-friendly console format.
+//
+// Newline-delimited JSON is what log shippers expect. Apart from the
+// format, logging is set up as it is by SetGlobalZerologToFile: entries
+// have RFC 3339 Nano timestamps in their `time` field, the file and line
+// number in their `caller` field, and support stack traces.
+//
+// If the log file cannot be opened then the global log is left unchanged.
+func SetGlobalZerologJSONToFile(logName string, level zerolog.Level) error {
+	_, err := ConfigureGlobalZerolog(
+		WithFile(logName), WithLevel(level), WithJSON())
+	return err
+} // SetGlobalZerologJSONToFile
+
+// SetGlobalZerologToWriter sets up the global log with the given logging
+// `level` to write to `w`, e.g., an in-memory buffer in a test, or a network
+// connection, rather than to a file; see WithWriter. Log entries are written
+// as JSON if `json` is true, and are otherwise formatted for humans, as they
+// are by SetGlobalZerologToFile:
+//
+//	```go
+//	var buff bytes.Buffer
+//	veil.SetGlobalZerologToWriter(&buff, zerolog.DebugLevel, true)
+//
+// The caller owns `w`, and so must keep it usable for as long as it is the
+// global log's writer, and close it, if need be, afterwards.
+func SetGlobalZerologToWriter(w io.Writer, level zerolog.Level, json bool) {
+	opts := []LoggerOption{WithWriter(w), WithLevel(level)}
+	if json {
+		opts = append(opts, WithJSON())
+	}
+	// cannot fail, as there is no log file to open
+	_, err := ConfigureGlobalZerolog(opts...)
+	IgnoreError(err)
+} // SetGlobalZerologToWriter
+
+// SetGlobalZerologToFileNoCaller sets up the global log like
+// SetGlobalZerologToFile does, except that log entries do not include the
+// file and line number where they were created. This keeps the entries
+// shorter, and keeps source file paths out of shipped logs.
+//
+// Log entries still have timestamps, and stack traces can still be logged.
+func SetGlobalZerologToFileNoCaller(logName string, level zerolog.Level) error {
+	_, err := ConfigureGlobalZerolog(
+		WithFile(logName), WithLevel(level), WithoutCaller())
+	return err
+} // SetGlobalZerologToFileNoCaller
+
+// SetGlobalZerologToFileTimeFormat sets up the global log like
+// SetGlobalZerologToFile does, except that the timestamps of log entries
+// use `timeFormat`, as used by time.Time.Format, rather than the default
+// "Mon 02 Jan 2006, 15:04:05.000", e.g., a short "15:04:05" for development,
+// or time.RFC3339 for production.
+//
+// An error is returned, and the global log is left unchanged, if
+// `timeFormat` formats a time as an empty string, or if the log file
+// cannot be opened.
+func SetGlobalZerologToFileTimeFormat(
+	logName, timeFormat string,
+	level zerolog.Level,
+) error {
+	_, err := ConfigureGlobalZerolog(
+		WithFile(logName), WithLevel(level), WithTimeFormat(timeFormat))
+	return err
+} // SetGlobalZerologToFileTimeFormat
+
+// SetGlobalZerologToFileWithSkip sets up the global log like
+// SetGlobalZerologToFile does, except that the file and line number in
+// log entries are those of the caller `skipFrames` stack frames further up
+// than usual; see WithCallerSkip.
+//
+// The frames are skipped in addition to those that zerolog skips by
+// default, so a `skipFrames` of zero preserves the usual behavior. Only
+// the global log is affected; zerolog.CallerSkipFrameCount is not changed.
+func SetGlobalZerologToFileWithSkip(
+	logName string,
+	level zerolog.Level,
+	skipFrames int,
+) error {
+	_, err := ConfigureGlobalZerolog(
+		WithFile(logName), WithLevel(level), WithCallerSkip(skipFrames))
+	return err
+} // SetGlobalZerologToFileWithSkip
+
+// SetGlobalZerologToFileWithFields sets up the global log like
+// SetGlobalZerologToFile does, except that each of `fields` is added, as a
+// string, to every log entry, e.g.:
+//
+//	```go
+//	err := veil.SetGlobalZerologToFileWithFields("app.log", level,
+//	    map[string]string{
+//	        "service": "billing",
+//	        "version": "1.4.2",
+//	        "host":    host,
+//	    })
+//
+// The values are never turned into numbers or other types, even if they
+// look like them. See WithFields.
+func SetGlobalZerologToFileWithFields(
+	logName string,
+	level zerolog.Level,
+	fields map[string]string,
+) error {
+	_, err := ConfigureGlobalZerolog(
+		WithFile(logName), WithLevel(level), WithFields(fields))
+	return err
+} // SetGlobalZerologToFileWithFields
+
+// SetGlobalZerologSampled sets up the global log like
+// SetGlobalZerologToFile does, except that only every `every`th log entry
+// is written, starting with the first; see WithSampling. This stops a hot
+// code path that logs the same thing thousands of times a second from
+// flooding the log file.
+//
+// The trade-off is that the other log entries, of every level, are dropped
+// and lost. A zero `every` is an error, and leaves the global log unchanged.
+func SetGlobalZerologSampled(
+	logName string,
+	level zerolog.Level,
+	every uint32,
+) error {
+	_, err := ConfigureGlobalZerolog(
+		WithFile(logName), WithLevel(level), WithSampling(every))
+	return err
+} // SetGlobalZerologSampled
+
+// SetGlobalZerologToFilePerm sets up the global log like
+// SetGlobalZerologToFile does, except that the log file is created with
+// `perm` permissions (before the umask) rather than 0o644. For example,
+// 0o600 keeps the log private to the current user, and 0o640 also lets
+// a log group read it.
+//
+// As with os.OpenFile, the permissions are only used when the log file is
+// created: the permissions of an existing log file are left as they are.
+func SetGlobalZerologToFilePerm(
+	logName string,
+	level zerolog.Level,
+	perm os.FileMode,
+) error {
+	_, err := ConfigureGlobalZerolog(
+		WithFile(logName), WithLevel(level), WithFilePerm(perm))
+	return err
+} // SetGlobalZerologToFilePerm
+
+// SetGlobalZerologBuffered sets up the global log like
+// SetGlobalZerologToFile does, except that log entries are written to the
+// log file asynchronously, through a buffer holding up to `bufSize` entries;
+// see WithBuffer. Logging then never waits for the log file to be written,
+// which suits programs that log heavily.
+//
+// When the buffer is full the oldest entries that have not yet been written
+// are dropped, and the number dropped is reported on standard error. The
+// returned io.Closer must be closed before the program exits, as closing it
+// is what writes the entries remaining in the buffer:
+//
+//	```go
+//	closer, err := veil.SetGlobalZerologBuffered("app.log", level, 1000)
+//	if err != nil {
+//	    return err
+//	}
+//	defer closer.Close()
+//
+// If the log file cannot be opened then the global log is left unchanged,
+// and a nil closer is returned along with the error.
+func SetGlobalZerologBuffered(
+	logName string,
+	level zerolog.Level,
+	bufSize int,
+) (io.Closer, error) {
+	return ConfigureGlobalZerolog(
+		WithFile(logName), WithLevel(level), WithBuffer(bufSize))
+} // SetGlobalZerologBuffered
+
+// SetGlobalZerologDualFile sets up the global log like SetGlobalZerologToFile
+// does, logging entries at `mainLevel` or above to the file named `mainLog`,
+// and also logging the entries at `errorLevel` or above to the file named
+// `errorLog`; see WithLevelFile. For example, warnings and errors can be kept
+// in a separate file, for alerting, while everything goes to the main log:
+//
+//	```go
+//	closer, err := veil.SetGlobalZerologDualFile(
+//	    "app.log", "app-errors.log", zerolog.InfoLevel, zerolog.WarnLevel)
+//	if err != nil {
+//	    return err
+//	}
+//	defer closer.Close()
+//
+// The returned io.Closer closes both of the log files. If either of the log
+// files cannot be opened then the global log is left unchanged, and a nil
+// closer is returned along with the error.
+func SetGlobalZerologDualFile(
+	mainLog, errorLog string,
+	mainLevel, errorLevel zerolog.Level,
+) (io.Closer, error) {
+	return ConfigureGlobalZerolog(
+		WithFile(mainLog), WithLevel(mainLevel), WithLevelFile(errorLog, errorLevel))
+} // SetGlobalZerologDualFile
+
+// SetGlobalZerologWithMetrics sets up the global log like
+// SetGlobalZerologToFile does, and also counts the log entries made at each
+// logging level, e.g., so that a metrics endpoint can report error rates.
+// The returned `counts` function returns a snapshot of the counts so far,
+// holding only the levels with at least one log entry:
+//
+//	```go
+//	counts, closer, err := veil.SetGlobalZerologWithMetrics("app.log", level)
+//	if err != nil {
+//	    return err
+//	}
+//	defer closer.Close()
+//	...
+//	errorCount := counts()[zerolog.ErrorLevel]
+//
+// An entry is counted just before it is written, by a hook, so log entries
+// below the logging level, or dropped by sampling, are not counted. The
+// counts are safe to update and read from multiple goroutines at once.
+//
+// If the log file cannot be opened then the global log is left unchanged,
+// and nil `counts` and `closer` are returned along with the error.
+func SetGlobalZerologWithMetrics(
+	logName string,
+	level zerolog.Level,
+) (counts func() map[zerolog.Level]int64, closer io.Closer, err error) {
+	counter := &levelCounter{}
+	closer, err = ConfigureGlobalZerolog(
+		WithFile(logName), WithLevel(level), WithHook(counter))
+	if err != nil {
+		return nil, nil, err
+	}
+	return counter.counts, closer, nil
+} // SetGlobalZerologWithMetrics
+
+// levelCounter is a zerolog.Hook that counts the log entries made at each
+// of zerolog's logging levels, from trace to no level.
+type levelCounter struct {
+	n [zerolog.NoLevel - zerolog.TraceLevel + 1]atomic.Int64
+}
+
+// Run counts the log entry at `level`.
+func (c *levelCounter) Run(_ *zerolog.Event, level zerolog.Level, _ string) {
+	if i := int(level - zerolog.TraceLevel); i >= 0 && i < len(c.n) {
+		c.n[i].Add(1)
+	}
+} // Run
+
+// counts returns a snapshot of the non-zero counts, by logging level.
+func (c *levelCounter) counts() map[zerolog.Level]int64 {
+	counts := make(map[zerolog.Level]int64)
+	for i := range c.n {
+		if n := c.n[i].Load(); n > 0 {
+			counts[zerolog.TraceLevel+zerolog.Level(i)] = n
+		}
+	}
+	return counts
+} // counts
+
+// NewFileLogger returns a new logger, with the given logging `level`, that
+// writes to a file named `logName`, along with an io.Closer for the file.
+//
+// The logger is configured like the global log set up by
+// SetGlobalZerologToFile, but neither the global log nor zerolog's global
+// logging level is changed. This lets any number of independent loggers
+// coexist in one process, which suits libraries and parallel tests.
+//
+// The logger writes its timestamps in the RFC 3339 Nano format itself,
+// without changing zerolog's process-wide timestamp format. Note that
+// zerolog's global level still applies on top of `level`, and that stack
+// traces are only logged once zerolog's process-wide stack trace marshaler
+// has been set, for example by SetGlobalZerologToFile, as zerolog cannot
+// configure this per logger.
+//
+// If the log file cannot be opened then a disabled logger and a nil
+// closer are returned along with the error.
+func NewFileLogger(
+	logName string,
+	level zerolog.Level,
+) (zerolog.Logger, io.Closer, error) {
+	cfg := newLoggerConfig([]LoggerOption{WithFile(logName)})
+	cfg.stampFormat = time.RFC3339Nano
+	logger, closer, err := cfg.build()
+	if err != nil {
+		return zerolog.Nop(), nil, err
+	}
+	return logger.Level(level), closer, nil
+} // NewFileLogger
+
+// ParseLevel returns the zerolog logging level named `s`.
+//
+// The name is case-insensitive, and any surrounding white space is ignored.
+// The standard zerolog level names ("trace", "debug", "info", "warn",
+// "error", "fatal", "panic", and "disabled") are accepted, as is "warning"
+// as an alias for "warn". Any other name is an error.
+func ParseLevel(s string) (zerolog.Level, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if level, ok := levelNames[name]; ok {
+		return level, nil
+	}
+	return zerolog.NoLevel, fmt.Errorf("unknown log level %q", s)
+} // ParseLevel
+
+// levelNames maps each lowercase level name accepted by ParseLevel
+// to its zerolog logging level.
+var levelNames = map[string]zerolog.Level{
+	"trace":    zerolog.TraceLevel,
+	"debug":    zerolog.DebugLevel,
+	"info":     zerolog.InfoLevel,
+	"warn":     zerolog.WarnLevel,
+	"warning":  zerolog.WarnLevel,
+	"error":    zerolog.ErrorLevel,
+	"fatal":    zerolog.FatalLevel,
+	"panic":    zerolog.PanicLevel,
+	"disabled": zerolog.Disabled,
+}
+
+// LevelFromEnv returns the logging level named by the environment variable
+// `key`, e.g., "LOG_LEVEL", as parsed by ParseLevel. This lets the logging
+// level be changed without recompiling the program.
+//
+// The `fallback` level is returned if the environment variable is not set,
+// or is empty. It is also returned if the environment variable does not name
+// a logging level, in which case a warning is logged using the global log.
+func LevelFromEnv(key string, fallback zerolog.Level) zerolog.Level {
+	value := os.Getenv(key)
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	level, err := ParseLevel(value)
+	if err != nil {
+		l := GlobalLogger()
+		l.Warn().Err(err).Str("env", key).Stringer("fallback", fallback).
+			Msg("ignoring invalid log level from the environment")
+		return fallback
+	}
+	return level
+} // LevelFromEnv
+
+// SetGlobalZerologToFileByName is like SetGlobalZerologToFile, except that
+// the logging level is given by its name, `levelName`, as accepted by
+// ParseLevel. An unknown level name is an error, and leaves the global
+// log unchanged.
+func SetGlobalZerologToFileByName(logName, levelName string) error {
+	level, err := ParseLevel(levelName)
+	if err != nil {
+		return err
+	}
+	return SetGlobalZerologToFile(logName, level)
+} // SetGlobalZerologToFileByName
+
+// SetGlobalZerologFromEnv sets up the global log as described by the
+// following environment variables, each of which is optional:
+//
+//   - LOG_FILE is the name of the log file, which may start with "~" for
+//     the user's home directory, as expanded by ExpandTilde. By default the
+//     log is written to standard error.
+//   - LOG_LEVEL is the logging level, as accepted by ParseLevel. The
+//     default is "info".
+//   - LOG_FORMAT is either "console", for human-friendly log entries, or
+//     "json", for newline-delimited JSON ones. The default is "console".
+//
+// An environment variable that is set but empty counts as unset. Otherwise
+// logging is set up as it is by ConfigureGlobalZerolog, and the returned
+// io.Closer closes the log file, if any.
+//
+// An invalid LOG_LEVEL or LOG_FORMAT is an error, as is a log file that
+// cannot be opened; the global log is then left unchanged.
+func SetGlobalZerologFromEnv() (io.Closer, error) {
+	opts := []LoggerOption{}
+	if name := strings.TrimSpace(os.Getenv("LOG_FILE")); name != "" {
+		path, err := ExpandTilde(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOG_FILE: %w", err)
+		}
+		opts = append(opts, WithFile(path))
+	}
+	if name := strings.TrimSpace(os.Getenv("LOG_LEVEL")); name != "" {
+		level, err := ParseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+		}
+		opts = append(opts, WithLevel(level))
+	}
+	format := strings.ToLower(strings.TrimSpace(os.Getenv("LOG_FORMAT")))
+	switch format {
+	case "", "console":
+	case "json":
+		opts = append(opts, WithJSON())
+	default:
+		return nil, fmt.Errorf("invalid LOG_FORMAT: unknown log format %q", format)
+	}
+	return ConfigureGlobalZerolog(opts...)
+} // SetGlobalZerologFromEnv
+
+// StdLoggerAt returns a standard library logger whose output is logged,
+// at the given logging `level`, by the global log. This lets the global
+// log be used by libraries that only accept a *log.Logger.
+//
+// Each line written by the returned logger becomes one log entry, with the
+// line (without its trailing newline) as the message, and so has the global
+// log's timestamp and other fields. The global log is looked up for each
+// line, so the logger follows any later changes to the global log.
+func StdLoggerAt(level zerolog.Level) *stdlog.Logger {
+	return stdlog.New(stdLogWriter{level: level}, "", 0)
+} // StdLoggerAt
+
+// ContextWithLogger returns a copy of `ctx` that carries the logger `l`,
+// for LoggerFromContext to retrieve. This lets a request handler enrich a
+// logger with request-scoped fields, such as a request ID, and pass it to
+// the functions that it calls along with the context:
+//
+//	```go
+//	l := log.With().Str("request_id", id).Logger()
+//	ctx = veil.ContextWithLogger(ctx, l)
+func ContextWithLogger(ctx context.Context, l zerolog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+} // ContextWithLogger
+
+// LoggerFromContext returns the logger carried by `ctx`, as added by
+// ContextWithLogger, or the global log if `ctx` does not carry a logger.
+// A usable logger is always returned.
+func LoggerFromContext(ctx context.Context) zerolog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(zerolog.Logger); ok {
+		return l
+	}
+	return GlobalLogger()
+} // LoggerFromContext
+
+// loggerKey is the context key for the logger added by ContextWithLogger.
+type loggerKey struct{}
+
+// stdLogWriter is an io.Writer that logs each write, as a message at
+// its logging `level`, using the global log.
+type stdLogWriter struct {
+	level zerolog.Level
+}
+
+// stdLogCallerSkip is the number of stack frames to skip, from
+// stdLogWriter.Write, to reach the caller of a standard library logger's
+// print function: Write itself, the logger's output method, and the
+// print function.
+const stdLogCallerSkip = 3
+
+// Write logs `p`, without any trailing newline, as a single message.
+func (w stdLogWriter) Write(p []byte) (int, error) {
+	logger := GlobalLogger()
+	logger.WithLevel(w.level).CallerSkipFrame(stdLogCallerSkip).
+		Msg(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+} // Write
+
+// openLogFile opens the file named `logName` for appending log entries,
+// creating it with `perm` permissions if it does not already exist.
+func openLogFile(logName string, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(logName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+} // openLogFile
+
+// logCloser is an io.Closer for a log file, or for a writer, such as a
+// log buffer, that closes the log file when it is closed.
+//
+// The file is only ever closed once, no matter how many times
+// Close is called.
+type logCloser struct {
+	once sync.Once
+	file io.Closer
+	err  error
+}
+
+// Close closes the log file. Calling Close more than once
+// returns the same result as the first call did.
+func (c *logCloser) Close() error {
+	c.once.Do(func() {
+		c.err = c.file.Close()
+	})
+	return c.err
+} // Close
+
+// logFile is both the writer for a log file and its io.Closer.
+//
+// Closing it waits for any writes in progress to finish, and writes made
+// after it is closed are quietly discarded rather than failing. This lets
+// the global log be reconfigured while other goroutines log through the
+// previous logger: each entry either reaches the previous log file in full,
+// or is dropped, and no write error is reported.
+type logFile struct {
+	mu     sync.RWMutex
+	file   *os.File
+	closed bool
+	err    error
+}
+
+// Write writes `p` to the log file, unless it has been closed.
+func (lf *logFile) Write(p []byte) (int, error) {
+	lf.mu.RLock()
+	defer lf.mu.RUnlock()
+	if lf.closed {
+		return len(p), nil
+	}
+	return lf.file.Write(p)
+} // Write
+
+// Close closes the log file. Calling Close more than once
+// returns the same result as the first call did.
+func (lf *logFile) Close() error {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if !lf.closed {
+		lf.closed = true
+		lf.err = lf.file.Close()
+	}
+	return lf.err
+} // Close
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta
@@ -0,0 +1,816 @@
+// File: zerolog_test.go
+// SPDX-License-Identifier: GPL-3.0-or-later
+// Copyright (c) 2024 Justin Hanekom
+// -*- mode: Go -*-
+
+/*
+  This file is part of veil - minor enhancements to Go libraries.
+
+  veil is free software: you can redistribute it and/or modify it
+  under the terms of the GNU General Public License as published by
+  the Free Software Foundation, either version 3 of the License, or
+  (at your option) any later version.
+
+  veil is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with go-veil. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package veil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSetGlobalZerologToFileWithCloser(t *testing.T) {
+	resetGlobalLog(t)
+	logName := filepath.Join(t.TempDir(), "app.log")
+	closer, err := SetGlobalZerologToFileWithCloser(logName, zerolog.InfoLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// zerolog reports the failed write on standard error
+	_, captureErr := CaptureStderr(func() {
+		l := GlobalLogger()
+		l.Info().Msg("after close")
+	})
+	if captureErr != nil {
+		t.Errorf("logging after the close: %v, want it to be safe", captureErr)
+	}
+	if err := closer.Close(); err != nil {
+		t.Errorf("second Close() = %v, want nil", err)
+	}
+	if data := readLogFile(t, logName); strings.Contains(data, "after close") {
+		t.Errorf("log file = %q, has an entry logged after the close", data)
+	}
+} // TestSetGlobalZerologToFileWithCloser
+
+func TestSetGlobalZerologJSONToFile(t *testing.T) {
+	resetGlobalLog(t)
+	logName := filepath.Join(t.TempDir(), "app.json")
+	if err := SetGlobalZerologJSONToFile(logName, zerolog.InfoLevel); err != nil {
+		t.Fatal(err)
+	}
+	l := GlobalLogger()
+	l.Warn().Msg("as JSON")
+	data, err := os.ReadFile(logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("log file = %q, want one line", data)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "warn" || entry["message"] != "as JSON" {
+		t.Errorf("entry = %v, want the warning", entry)
+	}
+	if caller, _ := entry["caller"].(string); !strings.Contains(caller, "zerolog_test.go:") {
+		t.Errorf("caller = %v, want this file", entry["caller"])
+	}
+	stamp, _ := entry["time"].(string)
+	if _, err := time.Parse(time.RFC3339Nano, stamp); err != nil {
+		t.Errorf("time = %v, want an RFC 3339 Nano timestamp: %v", entry["time"], err)
+	}
+} // TestSetGlobalZerologJSONToFile
+
+func TestSetGlobalZerologToConsoleAndFile(t *testing.T) {
+	resetGlobalLog(t)
+	logName := filepath.Join(t.TempDir(), "app.log")
+	var closer io.Closer
+	var err error
+	console, captureErr := CaptureStderr(func() {
+		closer, err = SetGlobalZerologToConsoleAndFile(logName, zerolog.InfoLevel)
+		if err != nil {
+			return
+		}
+		l := GlobalLogger()
+		l.Info().Msg("to both")
+		SetGlobalZerologNop()
+	})
+	if err != nil || captureErr != nil {
+		t.Fatal(err, captureErr)
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(console, "to both") {
+		t.Errorf("standard error = %q, want the message", console)
+	}
+	if !strings.Contains(string(data), "to both") {
+		t.Errorf("log file = %q, want the message", data)
+	}
+} // TestSetGlobalZerologToConsoleAndFile
+
+func TestNewFileLogger(t *testing.T) {
+	resetGlobalLog(t)
+	timeFormat := zerolog.TimeFieldFormat
+	t.Cleanup(func() { zerolog.TimeFieldFormat = timeFormat })
+	var buff bytes.Buffer
+	SetGlobalZerologToWriter(&buff, zerolog.DebugLevel, true)
+	zerolog.TimeFieldFormat = time.RFC3339
+	logName := filepath.Join(t.TempDir(), "audit.log")
+	logger, closer, err := NewFileLogger(logName, zerolog.WarnLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	logger.Info().Msg("below the level")
+	logger.Warn().Msg("at the level")
+	logged := readLogFile(t, logName)
+	if strings.Contains(logged, "below the level") ||
+		!strings.Contains(logged, "at the level") {
+		t.Errorf("log file = %q, want only the warning", logged)
+	}
+	if level := zerolog.GlobalLevel(); level != zerolog.DebugLevel {
+		t.Errorf("zerolog.GlobalLevel() = %v, want %v", level, zerolog.DebugLevel)
+	}
+	if zerolog.TimeFieldFormat != time.RFC3339 {
+		t.Errorf("zerolog.TimeFieldFormat = %q, want %q",
+			zerolog.TimeFieldFormat, time.RFC3339)
+	}
+	l := GlobalLogger()
+	l.Debug().Msg("to the global log")
+	if !strings.Contains(buff.String(), "to the global log") ||
+		strings.Contains(buff.String(), "at the level") {
+		t.Errorf("global log = %q, want only its own entry", buff.String())
+	}
+} // TestNewFileLogger
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]zerolog.Level{
+		"trace":    zerolog.TraceLevel,
+		"debug":    zerolog.DebugLevel,
+		"Info":     zerolog.InfoLevel,
+		" warn\n":  zerolog.WarnLevel,
+		"WARNING":  zerolog.WarnLevel,
+		"error":    zerolog.ErrorLevel,
+		"fatal":    zerolog.FatalLevel,
+		"panic":    zerolog.PanicLevel,
+		"disabled": zerolog.Disabled,
+	} {
+		if level, err := ParseLevel(s); err != nil || level != want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", s, level, err, want)
+		}
+	}
+	for _, s := range []string{"", "verbose", "warnings", "1"} {
+		if _, err := ParseLevel(s); err == nil {
+			t.Errorf("ParseLevel(%q) err = nil, want an error", s)
+		}
+	}
+} // TestParseLevel
+
+func TestSetGlobalZerologToFileByName(t *testing.T) {
+	resetGlobalLog(t)
+	logName := filepath.Join(t.TempDir(), "app.log")
+	if err := SetGlobalZerologToFileByName(logName, "loud"); err == nil {
+		t.Error("err = nil, want an error for an unknown level name")
+	}
+	if _, err := os.Stat(logName); err == nil {
+		t.Error("the log file was created for an unknown level name")
+	}
+	if err := SetGlobalZerologToFileByName(logName, "Warning"); err != nil {
+		t.Fatal(err)
+	}
+	if zerolog.GlobalLevel() != zerolog.WarnLevel {
+		t.Errorf("global level = %v, want warn", zerolog.GlobalLevel())
+	}
+} // TestSetGlobalZerologToFileByName
+
+func TestLevelFromEnv(t *testing.T) {
+	resetGlobalLog(t)
+	var buff bytes.Buffer
+	SetGlobalZerologToWriter(&buff, zerolog.InfoLevel, true)
+	const key = "VEIL_TEST_LOG_LEVEL"
+	t.Setenv(key, "debug")
+	if level := LevelFromEnv(key, zerolog.InfoLevel); level != zerolog.DebugLevel {
+		t.Errorf("LevelFromEnv() = %v with %s set, want debug", level, key)
+	}
+	os.Unsetenv(key)
+	if level := LevelFromEnv(key, zerolog.ErrorLevel); level != zerolog.ErrorLevel {
+		t.Errorf("LevelFromEnv() = %v with %s unset, want the fallback", level, key)
+	}
+	if buff.Len() != 0 {
+		t.Errorf("log = %q, want nothing logged for valid or unset levels", buff.String())
+	}
+	t.Setenv(key, "loud")
+	if level := LevelFromEnv(key, zerolog.ErrorLevel); level != zerolog.ErrorLevel {
+		t.Errorf("LevelFromEnv() = %v with %s invalid, want the fallback", level, key)
+	}
+	if n := strings.Count(buff.String(), "\n"); n != 1 ||
+		!strings.Contains(buff.String(), `"level":"warn"`) ||
+		!strings.Contains(buff.String(), key) {
+		t.Errorf("log = %q, want a single warning naming %s", buff.String(), key)
+	}
+} // TestLevelFromEnv
+
+func TestSetGlobalZerologToFileNoCaller(t *testing.T) {
+	resetGlobalLog(t)
+	logName := filepath.Join(t.TempDir(), "app.log")
+	if err := SetGlobalZerologToFileNoCaller(logName, zerolog.InfoLevel); err != nil {
+		t.Fatal(err)
+	}
+	l := GlobalLogger()
+	l.Info().Msg("no caller")
+	if entry := readLogFile(t, logName); !strings.Contains(entry, "no caller") ||
+		strings.Contains(entry, "zerolog_test.go") {
+		t.Errorf("log file = %q, want the entry without its caller", entry)
+	}
+
+	jsonName := filepath.Join(t.TempDir(), "app.json")
+	_, err := ConfigureGlobalZerolog(WithFile(jsonName), WithJSON(), WithoutCaller())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = GlobalLogger()
+	l.Info().Msg("no caller")
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(readLogFile(t, jsonName)), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := entry["caller"]; ok {
+		t.Errorf("entry = %v, has a caller", entry)
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Errorf("entry = %v, has no timestamp", entry)
+	}
+} // TestSetGlobalZerologToFileNoCaller
+
+// readLogFile returns the contents of the log file named `logName`.
+func readLogFile(t *testing.T, logName string) string {
+	t.Helper()
+	data, err := os.ReadFile(logName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+} // readLogFile
+
+func TestSetGlobalZerologToFileWithSkip(t *testing.T) {
+	resetGlobalLog(t)
+	for skip := 0; skip <= 1; skip++ {
+		logName := filepath.Join(t.TempDir(), "app.log")
+		err := SetGlobalZerologToFileWithSkip(logName, zerolog.InfoLevel, skip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, line, _ := runtime.Caller(0)
+		want := logThroughWrapper("through a wrapper")
+		if skip == 1 {
+			want = line + 1 // the line that called the wrapper
+		}
+		caller := fmt.Sprintf("zerolog_test.go:%d ", want)
+		if entry := readLogFile(t, logName); !strings.Contains(entry, caller) {
+			t.Errorf("skip %d: log file = %q, want a caller of %q", skip, entry, caller)
+		}
+	}
+} // TestSetGlobalZerologToFileWithSkip
+
+// logThroughWrapper logs `msg` using the global log, as a logging helper
+// might, and returns the line number of the call that logs it.
+func logThroughWrapper(msg string) int {
+	l := GlobalLogger()
+	_, _, line, _ := runtime.Caller(0)
+	l.Info().Msg(msg)
+	return line + 1
+} // logThroughWrapper
+
+func TestSetGlobalZerologToFilePerm(t *testing.T) {
+	resetGlobalLog(t)
+	for _, perm := range []os.FileMode{0o600, 0o640} {
+		logName := filepath.Join(t.TempDir(), "app.log")
+		if err := SetGlobalZerologToFilePerm(logName, zerolog.InfoLevel, perm); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(logName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != perm {
+			t.Errorf("log file mode = %v, want %v", info.Mode().Perm(), perm)
+		}
+		// the permissions of an existing log file are left as they are
+		if err := SetGlobalZerologToFilePerm(logName, zerolog.InfoLevel, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		if info, err = os.Stat(logName); err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != perm {
+			t.Errorf("existing log file mode = %v, want %v", info.Mode().Perm(), perm)
+		}
+	}
+} // TestSetGlobalZerologToFilePerm
+
+func TestStdLoggerAt(t *testing.T) {
+	resetGlobalLog(t)
+	var buff bytes.Buffer
+	SetGlobalZerologToWriter(&buff, zerolog.InfoLevel, true)
+	std := StdLoggerAt(zerolog.WarnLevel)
+	std.Printf("from the %s logger", "standard")
+	_, _, line, _ := runtime.Caller(0)
+	var entry map[string]any
+	if err := json.Unmarshal(buff.Bytes(), &entry); err != nil {
+		t.Fatalf("log = %q: %v", buff.String(), err)
+	}
+	if entry["level"] != "warn" || entry["message"] != "from the standard logger" {
+		t.Errorf("entry = %v, want the line as a warning", entry)
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Errorf("entry = %v, has no timestamp", entry)
+	}
+	caller, _ := entry["caller"].(string)
+	if want := fmt.Sprintf("zerolog_test.go:%d", line-1); !strings.HasSuffix(caller, want) {
+		t.Errorf("caller = %q, want the Printf call, %s", caller, want)
+	}
+} // TestStdLoggerAt
+
+func TestSetGlobalZerologBuffered(t *testing.T) {
+	resetGlobalLog(t)
+	logName := filepath.Join(t.TempDir(), "app.log")
+	const bufSize, n = 1000, 500
+	closer, err := SetGlobalZerologBuffered(logName, zerolog.InfoLevel, bufSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := GlobalLogger()
+	for i := 0; i < n; i++ {
+		l.Info().Int("i", i).Msg("buffered")
+	}
+	SetGlobalZerologNop()
+	// closing writes the entries remaining in the buffer
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(readLogFile(t, logName), "\n"), "\n")
+	if len(lines) != n {
+		t.Fatalf("log file has %d lines, want %d", len(lines), n)
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, fmt.Sprintf("i=%d", i)) {
+			t.Errorf("line %d = %q, want entry %d", i, line, i)
+			break
+		}
+	}
+} // TestSetGlobalZerologBuffered
+
+func TestSetGlobalZerologToFileWithFields(t *testing.T) {
+	resetGlobalLog(t)
+	logName := filepath.Join(t.TempDir(), "app.log")
+	fields := map[string]string{
+		"service": "billing",
+		"version": "1.4",
+		"host":    "web-1",
+	}
+	if err := SetGlobalZerologToFileWithFields(logName, zerolog.InfoLevel, fields); err != nil {
+		t.Fatal(err)
+	}
+	l := GlobalLogger()
+	l.Info().Msg("first")
+	l.Warn().Msg("second")
+	lines := strings.Split(strings.TrimSuffix(readLogFile(t, logName), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("log file lines = %q, want two", lines)
+	}
+	for _, line := range lines {
+		for _, field := range []string{"service=billing", "version=1.4", "host=web-1"} {
+			if !strings.Contains(line, field) {
+				t.Errorf("line %q does not have %s", line, field)
+			}
+		}
+	}
+
+	// the values stay strings, even where they look like numbers
+	jsonName := filepath.Join(t.TempDir(), "app.json")
+	_, err := ConfigureGlobalZerolog(WithFile(jsonName), WithJSON(), WithFields(fields))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = GlobalLogger()
+	l.Info().Msg("typed")
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(readLogFile(t, jsonName)), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["version"] != "1.4" || entry["service"] != "billing" || entry["host"] != "web-1" {
+		t.Errorf("entry = %v, want the fields as strings", entry)
+	}
+} // TestSetGlobalZerologToFileWithFields
+
+func TestSetGlobalZerologSampled(t *testing.T) {
+	resetGlobalLog(t)
+	logName := filepath.Join(t.TempDir(), "app.log")
+	if err := SetGlobalZerologSampled(logName, zerolog.InfoLevel, 0); err == nil {
+		t.Error("err = nil, want an error for sampling every 0th entry")
+	}
+	if err := SetGlobalZerologSampled(logName, zerolog.InfoLevel, 10); err != nil {
+		t.Fatal(err)
+	}
+	l := GlobalLogger()
+	for i := 0; i < 100; i++ {
+		l.Warn().Int("i", i).Msg("hot path")
+	}
+	lines := strings.Split(strings.TrimSuffix(readLogFile(t, logName), "\n"), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("log file has %d lines, want 10", len(lines))
+	}
+	if !strings.HasSuffix(lines[0], "i=0") || !strings.HasSuffix(lines[1], "i=10") {
+		t.Errorf("log file lines = %q, want every 10th entry from the first", lines)
+	}
+} // TestSetGlobalZerologSampled
+
+func TestSetGlobalZerologToFileOrStderr(t *testing.T) {
+	resetGlobalLog(t)
+	missing := filepath.Join(t.TempDir(), "missing", "app.log")
+	var err error
+	stderr, captureErr := CaptureStderr(func() {
+		err = SetGlobalZerologToFileOrStderr(missing, zerolog.InfoLevel)
+		l := GlobalLogger()
+		l.Info().Msg("still logging")
+		SetGlobalZerologNop()
+	})
+	if captureErr != nil {
+		t.Fatal(captureErr)
+	}
+	if !errors.Is(err, ErrLogFellBackToStderr) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("err = %v, want ErrLogFellBackToStderr wrapping the open error", err)
+	}
+	if !strings.Contains(stderr, "still logging") {
+		t.Errorf("standard error = %q, want the log entry", stderr)
+	}
+
+	logName := filepath.Join(t.TempDir(), "app.log")
+	if err := SetGlobalZerologToFileOrStderr(logName, zerolog.InfoLevel); err != nil {
+		t.Errorf("err = %v, want nil when the log file can be opened", err)
+	}
+} // TestSetGlobalZerologToFileOrStderr
+
+func TestLoggerFromContext(t *testing.T) {
+	resetGlobalLog(t)
+	var global, request bytes.Buffer
+	SetGlobalZerologToWriter(&global, zerolog.InfoLevel, true)
+	// absent, so the global log is used
+	l := LoggerFromContext(context.Background())
+	l.Info().Msg("global")
+	if !strings.Contains(global.String(), `"message":"global"`) {
+		t.Errorf("global log = %q, want the entry", global.String())
+	}
+	// present, including in a child context
+	ctx := ContextWithLogger(context.Background(),
+		zerolog.New(&request).With().Str("request_id", "r-1").Logger())
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	l = LoggerFromContext(ctx)
+	l.Info().Msg("request")
+	if !strings.Contains(request.String(), `"request_id":"r-1"`) ||
+		strings.Contains(global.String(), "request") {
+		t.Errorf("request log = %q, global log = %q, want the entry in the request log",
+			request.String(), global.String())
+	}
+} // TestLoggerFromContext
+
+func TestSetGlobalZerologFromEnv(t *testing.T) {
+	resetGlobalLog(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, tt := range []struct {
+		file, level, format string
+		wantLevel           zerolog.Level
+		wantJSON            bool
+	}{
+		{wantLevel: zerolog.InfoLevel},
+		{file: "~/app.log", wantLevel: zerolog.InfoLevel},
+		{level: "debug", wantLevel: zerolog.DebugLevel},
+		{format: "JSON", wantLevel: zerolog.InfoLevel, wantJSON: true},
+		{file: "~/app.log", level: "warn", format: "json",
+			wantLevel: zerolog.WarnLevel, wantJSON: true},
+	} {
+		t.Setenv("LOG_FILE", tt.file)
+		t.Setenv("LOG_LEVEL", tt.level)
+		t.Setenv("LOG_FORMAT", tt.format)
+		logName := filepath.Join(home, "app.log")
+		os.Remove(logName)
+		var closer io.Closer
+		var err error
+		stderr, captureErr := CaptureStderr(func() {
+			if closer, err = SetGlobalZerologFromEnv(); err != nil {
+				return
+			}
+			l := GlobalLogger()
+			l.WithLevel(tt.wantLevel).Msg("from the environment")
+			l.WithLevel(tt.wantLevel - 1).Msg("below the level")
+			SetGlobalZerologNop()
+		})
+		if err != nil || captureErr != nil {
+			t.Fatal(err, captureErr)
+		}
+		if err := closer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		output := stderr
+		if tt.file != "" {
+			if stderr != "" {
+				t.Errorf("%+v: standard error = %q, want nothing", tt, stderr)
+			}
+			output = readLogFile(t, logName)
+		}
+		if !strings.Contains(output, "from the environment") ||
+			strings.Contains(output, "below the level") {
+			t.Errorf("%+v: log = %q, want only the entry at %v", tt, output, tt.wantLevel)
+		}
+		if isJSON := strings.HasPrefix(output, "{"); isJSON != tt.wantJSON {
+			t.Errorf("%+v: log = %q, want JSON %v", tt, output, tt.wantJSON)
+		}
+	}
+} // TestSetGlobalZerologFromEnv
+
+func TestSetGlobalZerologFromEnvInvalid(t *testing.T) {
+	resetGlobalLog(t)
+	for name, value := range map[string]string{
+		"LOG_FILE":   "~someone/app.log",
+		"LOG_LEVEL":  "loud",
+		"LOG_FORMAT": "xml",
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"LOG_FILE", "LOG_LEVEL", "LOG_FORMAT"} {
+				t.Setenv(key, "")
+			}
+			t.Setenv(name, value)
+			_, err := SetGlobalZerologFromEnv()
+			if err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("err = %v, want an error naming %s", err, name)
+			}
+		})
+	}
+} // TestSetGlobalZerologFromEnvInvalid
+
+func TestSetGlobalZerologAuto(t *testing.T) {
+	resetGlobalLog(t)
+	logName := filepath.Join(t.TempDir(), "app.log")
+	var err error
+	// standard error is a pipe, and so not a terminal, while captured
+	stderr, captureErr := CaptureStderr(func() {
+		if err = SetGlobalZerologAuto(logName, zerolog.InfoLevel); err == nil {
+			l := GlobalLogger()
+			l.Info().Msg("automatic")
+			SetGlobalZerologNop()
+		}
+	})
+	if err != nil || captureErr != nil {
+		t.Fatal(err, captureErr)
+	}
+	if stderr != "" {
+		t.Errorf("standard error = %q, want nothing when it is not a terminal", stderr)
+	}
+	if entry := readLogFile(t, logName); !strings.Contains(entry, "automatic") ||
+		strings.Contains(entry, "\x1b[") {
+		t.Errorf("log file = %q, want the entry without color", entry)
+	}
+} // TestSetGlobalZerologAuto
+
+func TestSetGlobalZerologToFileResolved(t *testing.T) {
+	resetGlobalLog(t)
+	cwd := chdirTemp(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for logName, want := range map[string]string{
+		"app.log":           filepath.Join(cwd, "app.log"),
+		"logs/../other.log": filepath.Join(cwd, "other.log"),
+		"~/home.log":        filepath.Join(home, "home.log"),
+	} {
+		absPath, closer, err := SetGlobalZerologToFileResolved(logName, zerolog.InfoLevel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !filepath.IsAbs(absPath) || absPath != want {
+			t.Errorf("SetGlobalZerologToFileResolved(%q) = %q, want %q", logName, absPath, want)
+		}
+		SetGlobalZerologNop()
+		if err := closer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(want); err != nil {
+			t.Errorf("log file not created: %v", err)
+		}
+	}
+	absPath, closer, err := SetGlobalZerologToFileResolved("missing/app.log", zerolog.InfoLevel)
+	if err == nil || absPath != "" || closer != nil {
+		t.Errorf("SetGlobalZerologToFileResolved() = %q, %v, %v, want only an error",
+			absPath, closer, err)
+	}
+} // TestSetGlobalZerologToFileResolved
+
+func TestSetGlobalZerologToFileTimeFormat(t *testing.T) {
+	resetGlobalLog(t)
+	logName := filepath.Join(t.TempDir(), "app.log")
+	err := SetGlobalZerologToFileTimeFormat(logName, "15:04:05", zerolog.InfoLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := GlobalLogger()
+	l.Info().Msg("short time")
+	entry := readLogFile(t, logName)
+	if !regexp.MustCompile(`^\d\d:\d\d:\d\d INF `).MatchString(entry) {
+		t.Errorf("log file = %q, want a 15:04:05 timestamp", entry)
+	}
+
+	err = SetGlobalZerologToFileTimeFormat(logName, "", zerolog.InfoLevel)
+	if err == nil {
+		t.Error("SetGlobalZerologToFileTimeFormat() with an empty format succeeded")
+	}
+	l = GlobalLogger()
+	l.Info().Msg("still short")
+	entry = readLogFile(t, logName)
+	if !strings.Contains(entry, "still short") || strings.Count(entry, "\n") != 2 {
+		t.Errorf("log file = %q, want the global log unchanged", entry)
+	}
+} // TestSetGlobalZerologToFileTimeFormat
+
+func TestSetGlobalZerologDualFile(t *testing.T) {
+	resetGlobalLog(t)
+	dir := t.TempDir()
+	mainLog := filepath.Join(dir, "app.log")
+	errorLog := filepath.Join(dir, "app-errors.log")
+	closer, err := SetGlobalZerologDualFile(
+		mainLog, errorLog, zerolog.InfoLevel, zerolog.WarnLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := GlobalLogger()
+	l.Debug().Msg("not logged")
+	l.Info().Msg("main only")
+	l.Error().Msg("in both")
+	mainEntries, errorEntries := readLogFile(t, mainLog), readLogFile(t, errorLog)
+	if strings.Contains(mainEntries, "not logged") ||
+		!strings.Contains(mainEntries, "main only") ||
+		!strings.Contains(mainEntries, "in both") {
+		t.Errorf("main log = %q, want the info and error entries", mainEntries)
+	}
+	if strings.Contains(errorEntries, "main only") ||
+		!strings.Contains(errorEntries, "in both") {
+		t.Errorf("error log = %q, want only the error entry", errorEntries)
+	}
+
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// with both files closed, nothing more can be written to either of them
+	if _, err := CaptureOutput(func() { l.Error().Msg("after closing") }); err != nil {
+		t.Fatal(err)
+	}
+	if entries := readLogFile(t, mainLog); entries != mainEntries {
+		t.Errorf("main log = %q after closing, want %q", entries, mainEntries)
+	}
+	if entries := readLogFile(t, errorLog); entries != errorEntries {
+		t.Errorf("error log = %q after closing, want %q", entries, errorEntries)
+	}
+
+	_, err = SetGlobalZerologDualFile(
+		mainLog, filepath.Join(dir, "missing", "errors.log"),
+		zerolog.InfoLevel, zerolog.WarnLevel)
+	if err == nil {
+		t.Error("SetGlobalZerologDualFile() with a missing directory succeeded")
+	}
+} // TestSetGlobalZerologDualFile
+
+func TestSetGlobalZerologWithMetrics(t *testing.T) {
+	resetGlobalLog(t)
+	logName := filepath.Join(t.TempDir(), "app.log")
+	counts, closer, err := SetGlobalZerologWithMetrics(logName, zerolog.InfoLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	if got := counts(); len(got) != 0 {
+		t.Errorf("counts() = %v before logging, want none", got)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := GlobalLogger()
+			for j := 0; j < 25; j++ {
+				l.Debug().Msg("not logged, so not counted")
+				l.Info().Msg("info")
+				if j%5 == 0 {
+					l.Error().Msg("error")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	got := counts()
+	want := map[zerolog.Level]int64{zerolog.InfoLevel: 100, zerolog.ErrorLevel: 20}
+	if len(got) != len(want) || got[zerolog.InfoLevel] != 100 || got[zerolog.ErrorLevel] != 20 {
+		t.Errorf("counts() = %v, want %v", got, want)
+	}
+	if n := strings.Count(readLogFile(t, logName), "\n"); n != 120 {
+		t.Errorf("log file has %d entries, want 120", n)
+	}
+} // TestSetGlobalZerologWithMetrics
+
+func TestSetGlobalZerologToWriter(t *testing.T) {
+	resetGlobalLog(t)
+	var buff bytes.Buffer
+	SetGlobalZerologToWriter(&buff, zerolog.WarnLevel, true)
+	l := GlobalLogger()
+	l.Info().Msg("below the level")
+	l.Warn().Int("attempt", 3).Msg("to a buffer")
+	var entry map[string]any
+	if err := json.Unmarshal(buff.Bytes(), &entry); err != nil {
+		t.Fatalf("cannot parse %q: %v", buff.String(), err)
+	}
+	if entry["level"] != "warn" || entry["message"] != "to a buffer" ||
+		entry["attempt"] != 3.0 {
+		t.Errorf("entry = %v, want the warning", entry)
+	}
+
+	buff.Reset()
+	SetGlobalZerologToWriter(&buff, zerolog.InfoLevel, false)
+	l = GlobalLogger()
+	l.Info().Msg("as text")
+	if got := buff.String(); !strings.Contains(got, " INF ") ||
+		!strings.Contains(got, "as text") || strings.HasPrefix(got, "{") {
+		t.Errorf("buffer = %q, want a console formatted entry", got)
+	}
+} // TestSetGlobalZerologToWriter
+
+func TestNopLogger(t *testing.T) {
+	l := NopLogger()
+	if l.GetLevel() != zerolog.Disabled {
+		t.Errorf("NopLogger() level = %v, want disabled", l.GetLevel())
+	}
+	if l.Debug().Enabled() || l.Error().Enabled() {
+		t.Error("NopLogger() has enabled events")
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Debug().Str("key", "value").Int("n", 1).Msg("discarded")
+		l.Error().Err(io.EOF).Send()
+	})
+	if allocs != 0 {
+		t.Errorf("NopLogger() allocates %v times a log entry, want none", allocs)
+	}
+} // TestNopLogger
+
+func TestSetGlobalZerologNop(t *testing.T) {
+	resetGlobalLog(t)
+	var buff bytes.Buffer
+	SetGlobalZerologToWriter(&buff, zerolog.TraceLevel, true)
+	output, err := CaptureOutput(func() {
+		SetGlobalZerologNop()
+		l := GlobalLogger()
+		l.Debug().Msg("discarded")
+		l.Error().Msg("discarded too")
+	})
+	if err != nil || output != "" || buff.Len() != 0 {
+		t.Errorf("SetGlobalZerologNop() logged %q, with %q output, %v, want nothing",
+			buff.String(), output, err)
+	}
+} // TestSetGlobalZerologNop
+
+func TestSetGlobalZerologNopClosesLogFile(t *testing.T) {
+	resetGlobalLog(t)
+	c := &countingCloser{err: errors.New("cannot close")}
+	installGlobalZerolog(NopLogger(), zerolog.InfoLevel, c)
+	SetGlobalZerologNop()
+	if c.closed != 1 {
+		t.Errorf("previous log file closed %d times, want 1", c.closed)
+	}
+	SetGlobalZerologNop()
+	if c.closed != 1 {
+		t.Errorf("previous log file closed %d times, want 1", c.closed)
+	}
+} // TestSetGlobalZerologNopClosesLogFile
+
+// vim: set ft=go sw=4 sts=4 ts=4 ai ar si sta